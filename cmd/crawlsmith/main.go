@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 
-	"github.com/spf13/cobra"
 	"github.com/amosWeiskopf/crawlsmith/internal/config"
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
 	"github.com/amosWeiskopf/crawlsmith/pkg/analyzer"
 	"github.com/amosWeiskopf/crawlsmith/pkg/crawler"
 	"github.com/amosWeiskopf/crawlsmith/pkg/reporter"
+	"github.com/spf13/cobra"
 )
 
 var (
@@ -28,23 +35,119 @@ that provides deep website insights, content extraction, and AI-powered analysis
 var crawlCmd = &cobra.Command{
 	Use:   "crawl [URL]",
 	Short: "Crawl a website and extract content",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		url := args[0]
 		maxPerPath, _ := cmd.Flags().GetInt("max-per-path")
 		maxPathTypes, _ := cmd.Flags().GetInt("max-path-types")
-		
-		c, err := crawler.New(url, maxPerPath, maxPathTypes)
+		output, _ := cmd.Flags().GetString("output")
+		resumeID, _ := cmd.Flags().GetString("resume")
+		listFile, _ := cmd.Flags().GetString("list")
+		incremental, _ := cmd.Flags().GetBool("incremental")
+		warcDir, _ := cmd.Flags().GetString("warc")
+		checkOutlinks, _ := cmd.Flags().GetBool("check-outlinks")
+		phoneRegion, _ := cmd.Flags().GetString("phone-region")
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		setOptions := func(opts *crawler.Options) {
+			opts.MaxPerPath = maxPerPath
+			opts.MaxPathTypes = maxPathTypes
+			if incremental {
+				opts.Incremental = true
+			}
+			if checkOutlinks {
+				opts.CheckOutlinks = true
+			}
+			if warcDir != "" {
+				opts.WARCPath = warcDir
+			}
+			if phoneRegion != "" {
+				opts.PhoneRegion = phoneRegion
+			}
+		}
+
+		var c *crawler.WebCrawler
+		var list []string
+		switch {
+		case listFile != "" && (resumeID != "" || len(args) == 1):
+			return fmt.Errorf("--list cannot be combined with a URL or --resume")
+		case listFile != "":
+			list, err = readURLList(listFile)
+			if err != nil {
+				return err
+			}
+			c, err = newCrawler(cfg, list[0], setOptions)
+		case resumeID != "":
+			c, err = resumeCrawler(cfg, resumeID)
+		case len(args) == 1:
+			c, err = newCrawler(cfg, args[0], setOptions)
+		default:
+			return fmt.Errorf("a URL, --list <file> or --resume <crawl-id> is required")
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create crawler: %w", err)
 		}
-		
-		result, err := c.Crawl()
+
+		// List crawls are not journaled
+		if id := c.CrawlID(); id != "" && list == nil {
+			fmt.Printf("Crawl ID: %s\n", id)
+		}
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			c.OnPage(func(page *models.Page) {
+				fmt.Fprintf(os.Stderr, "%d %s\n", page.StatusCode, page.URL)
+			})
+			c.OnError(func(url string, err error) {
+				fmt.Fprintf(os.Stderr, "error %s: %v\n", url, err)
+			})
+		}
+
+		var result *models.CrawlResult
+		if list != nil {
+			result, err = c.CrawlURLs(cmd.Context(), list)
+		} else {
+			result, err = c.CrawlWithContext(cmd.Context())
+		}
 		if err != nil {
+			if c.CrawlID() != "" && list == nil && errors.Is(err, context.Canceled) {
+				return fmt.Errorf("crawl interrupted, continue with --resume %s", c.CrawlID())
+			}
 			return fmt.Errorf("crawl failed: %w", err)
 		}
-		
-		fmt.Printf("Crawled %d pages from %s\n", result.TotalPages, result.Domain)
+
+		if output != "" {
+			data, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal crawl results: %w", err)
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return fmt.Errorf("failed to write crawl results: %w", err)
+			}
+		}
+
+		if list != nil {
+			fmt.Printf("Crawled %d of %d listed URLs\n", result.TotalPages, len(list))
+		} else {
+			fmt.Printf("Crawled %d pages from %s\n", result.TotalPages, result.Domain)
+		}
+		if len(result.LinkChecks) > 0 {
+			broken := 0
+			for _, check := range result.LinkChecks {
+				if check.Error != "" || check.StatusCode >= 400 {
+					broken++
+				}
+			}
+			fmt.Printf("Checked %d outlinks: %d broken\n", len(result.LinkChecks), broken)
+		}
+		if result.PreviousCrawlID != "" {
+			changes := make(map[string]int)
+			for _, page := range result.Pages {
+				changes[page.Change]++
+			}
+			fmt.Printf("Compared with %s: %d new, %d changed, %d unchanged, %d removed\n",
+				result.PreviousCrawlID, changes[models.ChangeNew], changes[models.ChangeChanged],
+				changes[models.ChangeUnchanged], len(result.RemovedURLs))
+		}
 		return nil
 	},
 }
@@ -52,29 +155,53 @@ var crawlCmd = &cobra.Command{
 var analyzeCmd = &cobra.Command{
 	Use:   "analyze [URL]",
 	Short: "Perform comprehensive SEO analysis",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		url := args[0]
 		full, _ := cmd.Flags().GetBool("full")
-		
-		// First crawl
-		c, err := crawler.New(url, 50, 100)
+		archives, _ := cmd.Flags().GetStringSlice("warc")
+		cfg, err := loadConfig(cmd)
 		if err != nil {
-			return fmt.Errorf("failed to create crawler: %w", err)
+			return err
 		}
-		
-		crawlResult, err := c.Crawl()
-		if err != nil {
-			return fmt.Errorf("crawl failed: %w", err)
+
+		// First crawl, or rebuild an archived crawl offline
+		var crawlResult *models.CrawlResult
+		switch {
+		case len(archives) > 0:
+			crawlResult, err = crawler.ReadWARC(archives...)
+			if err != nil {
+				return fmt.Errorf("failed to read archived crawl: %w", err)
+			}
+		case len(args) == 1:
+			c, err := newCrawler(cfg, args[0], func(opts *crawler.Options) {
+				opts.MaxPerPath = 50
+				opts.MaxPathTypes = 100
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create crawler: %w", err)
+			}
+
+			crawlResult, err = c.CrawlWithContext(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("crawl failed: %w", err)
+			}
+		default:
+			return fmt.Errorf("a URL or --warc <file> is required")
 		}
-		
-		// Then analyze
-		a := analyzer.New()
+
+		// Then analyze, canonicalizing URLs the way the crawler does
+		a := analyzer.NewWithConfig(&analyzer.Config{
+			AnalyzePageRank:    true,
+			AnalyzeContent:     true,
+			AnalyzeTechnical:   true,
+			AnalyzePerformance: true,
+			Canonical:          crawler.OptionsFromConfig(cfg).Canonical,
+		})
 		analysis, err := a.Analyze(crawlResult, full)
 		if err != nil {
 			return fmt.Errorf("analysis failed: %w", err)
 		}
-		
+
 		fmt.Printf("Analysis complete: %v\n", analysis)
 		return nil
 	},
@@ -88,13 +215,13 @@ var reportCmd = &cobra.Command{
 		domain := args[0]
 		format, _ := cmd.Flags().GetString("format")
 		output, _ := cmd.Flags().GetString("output")
-		
+
 		r := reporter.New()
 		report, err := r.GenerateReport(domain, format)
 		if err != nil {
 			return fmt.Errorf("report generation failed: %w", err)
 		}
-		
+
 		if output != "" {
 			err = os.WriteFile(output, []byte(report), 0644)
 			if err != nil {
@@ -104,38 +231,112 @@ var reportCmd = &cobra.Command{
 		} else {
 			fmt.Println(report)
 		}
-		
+
 		return nil
 	},
 }
 
+// loadConfig loads the configuration named by the --config flag
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	return config.Load(configPath)
+}
+
+// newCrawler builds a crawler from the loaded configuration, letting the
+// calling command apply its own flags through set
+func newCrawler(cfg *config.Config, url string, set func(*crawler.Options)) (*crawler.WebCrawler, error) {
+	opts := crawler.OptionsFromConfig(cfg)
+	set(&opts)
+	return crawler.NewWithOptions(url, opts)
+}
+
+// readURLList reads one URL per line, skipping blank lines and # comments
+func readURLList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open URL list: %w", err)
+	}
+	defer f.Close()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			urls = append(urls, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URL list: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("URL list %s is empty", path)
+	}
+	return urls, nil
+}
+
+// resumeCrawler reopens a persisted crawl from the configured storage path
+func resumeCrawler(cfg *config.Config, crawlID string) (*crawler.WebCrawler, error) {
+	// Crawls are only persisted where OptionsFromConfig stores them
+	opts := crawler.OptionsFromConfig(cfg)
+	switch {
+	case cfg.Storage.Type != "file":
+		return nil, fmt.Errorf("cannot resume crawl %s: crawls are only persisted with storage type \"file\", not %q", crawlID, cfg.Storage.Type)
+	case opts.StoragePath == "":
+		return nil, fmt.Errorf("cannot resume crawl %s: no storage path is configured", crawlID)
+	}
+	c, err := crawler.Resume(opts.StoragePath, crawlID)
+	if err != nil {
+		return nil, err
+	}
+	// Credentials and proxies are not saved with the crawl
+	if err := c.SetAuth(opts.Auth); err != nil {
+		return nil, err
+	}
+	if err := c.SetNetwork(opts.Network); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func init() {
 	// Crawl command flags
 	crawlCmd.Flags().Int("max-per-path", 50, "Maximum pages per path pattern")
 	crawlCmd.Flags().Int("max-path-types", 100, "Maximum number of path types")
 	crawlCmd.Flags().String("output", "", "Output file for crawl results")
-	
+	crawlCmd.Flags().String("resume", "", "Resume an interrupted crawl by its crawl ID")
+	crawlCmd.Flags().Bool("incremental", false, "Only re-extract pages changed since the last completed crawl")
+	crawlCmd.Flags().String("warc", "", "Directory to archive requests and responses in as WARC files")
+	crawlCmd.Flags().String("list", "", "Crawl only the URLs in this file, one per line, without following links")
+	crawlCmd.Flags().Bool("check-outlinks", false, "With --list, check the status of every link on the listed pages")
+	crawlCmd.Flags().String("phone-region", "", "Region such as US or DE whose national phone numbers are recognized (default: from the site's country-code domain, else US)")
+
 	// Analyze command flags
 	analyzeCmd.Flags().Bool("full", false, "Perform full analysis including AI features")
 	analyzeCmd.Flags().String("output", "", "Output file for analysis results")
-	
+	analyzeCmd.Flags().StringSlice("warc", nil, "Analyze an archived crawl from these WARC files instead of crawling")
+
 	// Report command flags
 	reportCmd.Flags().String("format", "json", "Report format (json, html, markdown)")
 	reportCmd.Flags().String("output", "", "Output file for report")
-	
+
 	// Add commands to root
 	rootCmd.AddCommand(crawlCmd)
 	rootCmd.AddCommand(analyzeCmd)
 	rootCmd.AddCommand(reportCmd)
-	
+
 	// Global flags
 	rootCmd.PersistentFlags().String("config", "", "Config file path")
 	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose output")
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
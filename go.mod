@@ -3,6 +3,7 @@ module github.com/amosWeiskopf/crawlsmith
 go 1.23.0
 
 require (
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
 	github.com/markusmobius/go-trafilatura v1.12.2
 	github.com/spf13/cobra v1.8.0
 	github.com/spf13/viper v1.18.2
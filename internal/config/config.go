@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -43,8 +44,102 @@ type CrawlerConfig struct {
 	Timeout           time.Duration `mapstructure:"timeout"`
 	FollowRobotsTxt   bool          `mapstructure:"follow_robots_txt"`
 	ExtractContacts   bool          `mapstructure:"extract_contacts"`
+	PhoneRegion       string        `mapstructure:"phone_region"`
 	EnableJavaScript  bool          `mapstructure:"enable_javascript"`
 	MaxWorkers        int           `mapstructure:"max_workers"`
+	UseSitemaps       bool          `mapstructure:"use_sitemaps"`
+	Incremental       bool          `mapstructure:"incremental"`
+	RendererURL       string        `mapstructure:"renderer_url"`
+	MaxRetries        int           `mapstructure:"max_retries"`
+	SubdomainWordlist []string      `mapstructure:"subdomain_wordlist"`
+
+	// URL canonicalization used to deduplicate the crawl
+	Canonical CanonicalConfig `mapstructure:"canonical"`
+
+	// Limits past which URLs are quarantined as crawler traps
+	Traps TrapConfig `mapstructure:"traps"`
+
+	// Credentials for sites behind basic auth or a login form
+	Auth AuthConfig `mapstructure:"auth"`
+
+	// Proxies and the addresses the crawler may connect to
+	Network NetworkConfig `mapstructure:"network"`
+}
+
+// CanonicalConfig controls how URLs are canonicalized before deduplication
+type CanonicalConfig struct {
+	StripTrailingSlash bool                  `mapstructure:"strip_trailing_slash"`
+	KeepIndexFiles     bool                  `mapstructure:"keep_index_files"`
+	KeepTrackingParams bool                  `mapstructure:"keep_tracking_params"`
+	AllowParams        []string              `mapstructure:"allow_params"`
+	DenyParams         []string              `mapstructure:"deny_params"`
+	Sites              []SiteCanonicalConfig `mapstructure:"sites"`
+}
+
+// SiteCanonicalConfig holds query parameter rules for one host and its subdomains
+type SiteCanonicalConfig struct {
+	Host        string   `mapstructure:"host"`
+	AllowParams []string `mapstructure:"allow_params"`
+	DenyParams  []string `mapstructure:"deny_params"`
+}
+
+// TrapConfig holds the crawler trap detection limits; zero disables a check
+type TrapConfig struct {
+	MaxPathDepth      int `mapstructure:"max_path_depth"`
+	MaxSegmentRepeats int `mapstructure:"max_segment_repeats"`
+	MaxQueryParams    int `mapstructure:"max_query_params"`
+	MaxCounterSteps   int `mapstructure:"max_counter_steps"`
+	MaxNearDuplicates int `mapstructure:"max_near_duplicates"`
+}
+
+// AuthConfig holds the credentials used while crawling. Username, Password,
+// Token and Headers are sent to the crawled host; Hosts adds other hosts.
+type AuthConfig struct {
+	Username   string            `mapstructure:"username"`
+	Password   string            `mapstructure:"password"`
+	Token      string            `mapstructure:"token"`
+	Headers    map[string]string `mapstructure:"headers"`
+	Hosts      []HostAuthConfig  `mapstructure:"hosts"`
+	CookieFile string            `mapstructure:"cookie_file"` // Netscape cookies.txt
+	Login      LoginConfig       `mapstructure:"login"`
+}
+
+// HostAuthConfig holds the headers and credentials for one host and its subdomains
+type HostAuthConfig struct {
+	Host     string            `mapstructure:"host"`
+	Username string            `mapstructure:"username"`
+	Password string            `mapstructure:"password"`
+	Token    string            `mapstructure:"token"`
+	Headers  map[string]string `mapstructure:"headers"`
+}
+
+// LoginConfig describes a login form submitted before the crawl; an empty
+// URL disables it
+type LoginConfig struct {
+	URL           string             `mapstructure:"url"`
+	UsernameField string             `mapstructure:"username_field"`
+	PasswordField string             `mapstructure:"password_field"`
+	Username      string             `mapstructure:"username"`
+	Password      string             `mapstructure:"password"`
+	Fields        []LoginFieldConfig `mapstructure:"fields"`
+	SuccessText   string             `mapstructure:"success_text"`
+}
+
+// LoginFieldConfig is an extra login form field. It is a list entry rather
+// than a map key because form field names are case-sensitive.
+type LoginFieldConfig struct {
+	Name  string `mapstructure:"name"`
+	Value string `mapstructure:"value"`
+}
+
+// NetworkConfig holds the outgoing proxies and address rules. BlockPrivate
+// keeps a crawler run as a service away from internal networks.
+type NetworkConfig struct {
+	Proxies       []string `mapstructure:"proxies"`        // http://, https:// or socks5:// URLs
+	ProxyFailures int      `mapstructure:"proxy_failures"` // Consecutive failures before a proxy is dropped
+	BlockPrivate  bool     `mapstructure:"block_private"`
+	AllowCIDRs    []string `mapstructure:"allow_cidrs"`
+	DenyCIDRs     []string `mapstructure:"deny_cidrs"`
 }
 
 // APIConfig holds API keys and endpoints
@@ -76,9 +171,11 @@ type SerpAPIConfig struct {
 
 // StorageConfig holds storage configuration
 type StorageConfig struct {
-	Type      string `mapstructure:"type"` // "file", "database", "s3"
-	Path      string `mapstructure:"path"`
-	BatchSize int    `mapstructure:"batch_size"`
+	Type          string `mapstructure:"type"` // "file", "database", "s3"
+	Path          string `mapstructure:"path"`
+	BatchSize     int    `mapstructure:"batch_size"`
+	WARCPath      string `mapstructure:"warc_path"`        // Empty disables WARC archiving
+	WARCMaxSizeMB int    `mapstructure:"warc_max_size_mb"` // Size at which a new WARC file is started
 }
 
 // LoggingConfig holds logging configuration
@@ -154,8 +251,20 @@ func setDefaults() {
 	viper.SetDefault("crawler.timeout", "30s")
 	viper.SetDefault("crawler.follow_robots_txt", true)
 	viper.SetDefault("crawler.extract_contacts", true)
+	viper.SetDefault("crawler.phone_region", "")
 	viper.SetDefault("crawler.enable_javascript", false)
 	viper.SetDefault("crawler.max_workers", 10)
+	viper.SetDefault("crawler.use_sitemaps", true)
+	viper.SetDefault("crawler.incremental", false)
+	viper.SetDefault("crawler.renderer_url", "http://127.0.0.1:9222")
+	viper.SetDefault("crawler.max_retries", 3)
+	viper.SetDefault("crawler.traps.max_path_depth", 15)
+	viper.SetDefault("crawler.traps.max_segment_repeats", 2)
+	viper.SetDefault("crawler.traps.max_query_params", 8)
+	viper.SetDefault("crawler.traps.max_counter_steps", 50)
+	viper.SetDefault("crawler.traps.max_near_duplicates", 20)
+	viper.SetDefault("crawler.network.proxy_failures", 3)
+	viper.SetDefault("crawler.network.block_private", false)
 
 	// API defaults
 	viper.SetDefault("apis.openai.model", "gpt-4")
@@ -167,6 +276,7 @@ func setDefaults() {
 	viper.SetDefault("storage.type", "file")
 	viper.SetDefault("storage.path", "./data")
 	viper.SetDefault("storage.batch_size", 100)
+	viper.SetDefault("storage.warc_max_size_mb", 1024)
 
 	// Logging defaults
 	viper.SetDefault("logging.level", "info")
@@ -205,6 +315,28 @@ func loadFromEnv(config *Config) {
 	if apiKey := os.Getenv("SERPAPI_API_KEY"); apiKey != "" {
 		config.APIs.SerpAPI.APIKey = apiKey
 	}
+
+	// Crawl credentials
+	if username := os.Getenv("CRAWLSMITH_AUTH_USERNAME"); username != "" {
+		config.Crawler.Auth.Username = username
+	}
+	if password := os.Getenv("CRAWLSMITH_AUTH_PASSWORD"); password != "" {
+		config.Crawler.Auth.Password = password
+	}
+	if token := os.Getenv("CRAWLSMITH_AUTH_TOKEN"); token != "" {
+		config.Crawler.Auth.Token = token
+	}
+	if username := os.Getenv("CRAWLSMITH_LOGIN_USERNAME"); username != "" {
+		config.Crawler.Auth.Login.Username = username
+	}
+	if password := os.Getenv("CRAWLSMITH_LOGIN_PASSWORD"); password != "" {
+		config.Crawler.Auth.Login.Password = password
+	}
+
+	// Proxy URLs may hold credentials too
+	if proxies := os.Getenv("CRAWLSMITH_PROXIES"); proxies != "" {
+		config.Crawler.Network.Proxies = strings.Split(proxies, ",")
+	}
 }
 
 // Get returns the current configuration
@@ -18,33 +18,354 @@ type Page struct {
 	CrawledAt       time.Time `json:"crawled_at"`
 	StatusCode      int       `json:"status_code"`
 	PageRank        float64   `json:"pagerank"`
+	FinalURL        string    `json:"final_url,omitempty"`
+
+	// MetaRobots and XRobotsTag hold the robots directives found in the page
+	// and in its response headers; Indexable and Followable are the outcome
+	MetaRobots []string `json:"meta_robots,omitempty"`
+	XRobotsTag []string `json:"x_robots_tag,omitempty"`
+	Indexable  bool     `json:"indexable"`
+	Followable bool     `json:"followable"`
+
+	// OnPage is the rest of the SEO markup of an HTML page
+	OnPage *OnPage `json:"on_page,omitempty"`
+
+	// StructuredData holds the JSON-LD, Microdata and RDFa items of the page;
+	// SchemaTypes lists every schema.org type they use, nested ones included
+	StructuredData []StructuredData `json:"structured_data,omitempty"`
+	SchemaTypes    []string         `json:"schema_types,omitempty"`
+
+	// PhoneNumbers are the valid numbers behind Phones, normalized to E.164;
+	// EmailAddresses are Emails classified by role
+	PhoneNumbers   []PhoneNumber `json:"phone_numbers,omitempty"`
+	EmailAddresses []Email       `json:"email_addresses,omitempty"`
+
+	// LastModified and ContentHash let later crawls detect changes; Change is
+	// set by incremental crawls to "new", "changed", "unchanged" or "removed".
+	// Fingerprint is a simhash of Text that stays close for near-identical pages.
+	LastModified string `json:"last_modified,omitempty"`
+	ContentHash  string `json:"content_hash,omitempty"`
+	Change       string `json:"change,omitempty"`
+	Fingerprint  string `json:"fingerprint,omitempty"`
+
+	// RenderedHTML is the DOM after JavaScript ran. RenderedOnlyLinks and
+	// RawOnlyLinks are the links found in only one of the two documents.
+	RenderedHTML      string   `json:"rendered_html,omitempty"`
+	ConsoleErrors     []string `json:"console_errors,omitempty"`
+	RenderedOnlyLinks []string `json:"rendered_only_links,omitempty"`
+	RawOnlyLinks      []string `json:"raw_only_links,omitempty"`
+	RenderError       string   `json:"render_error,omitempty"`
+
+	// Response metadata. RedirectChain holds every hop before FinalURL and
+	// ClickDepth is the fewest links from the seed, or -1 if none lead here.
+	// DocumentType is set for pages whose content was extracted and Charset
+	// for those extracted from text, which is transcoded to UTF-8 first.
+	RedirectChain []RedirectHop       `json:"redirect_chain,omitempty"`
+	RedirectLoop  bool                `json:"redirect_loop,omitempty"`
+	Headers       map[string][]string `json:"headers,omitempty"`
+	ContentType   string              `json:"content_type,omitempty"`
+	DocumentType  string              `json:"document_type,omitempty"`
+	Charset       string              `json:"charset,omitempty"`
+	ContentLength int64               `json:"content_length"`
+	Compression   string              `json:"compression,omitempty"`
+	Timings       *Timings            `json:"timings,omitempty"`
+	ClickDepth    int                 `json:"click_depth"`
+}
+
+// OnPage is the SEO-relevant markup of an HTML page. URLs are absolute and
+// OpenGraph and TwitterCard map property names such as og:title to the first
+// value given. WordCount counts the words of the rendered body text and
+// ImagesMissingAlt the images without an alt attribute; an empty alt marks a
+// decorative image and is not counted.
+type OnPage struct {
+	Canonical        string            `json:"canonical,omitempty"`
+	Hreflangs        []Hreflang        `json:"hreflangs,omitempty"`
+	OpenGraph        map[string]string `json:"open_graph,omitempty"`
+	TwitterCard      map[string]string `json:"twitter_card,omitempty"`
+	Viewport         string            `json:"viewport,omitempty"`
+	Lang             string            `json:"lang,omitempty"`
+	Headings         []Heading         `json:"headings,omitempty"`
+	Images           int               `json:"images"`
+	ImagesMissingAlt int               `json:"images_missing_alt"`
+	WordCount        int               `json:"word_count"`
+	Prev             string            `json:"prev,omitempty"`
+	Next             string            `json:"next,omitempty"`
+	Favicon          string            `json:"favicon,omitempty"`
+}
+
+// Hreflang is an alternate version of a page for a language or region
+type Hreflang struct {
+	Lang string `json:"lang"`
+	URL  string `json:"url"`
+}
+
+// Heading is an H1 to H6 element in document order
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// StructuredData is one top-level item of structured data with the outcome of
+// validating it. Data is the item as JSON-LD would express it, with "@type"
+// and nested items as objects. Errors are problems that make the markup
+// unreadable, Missing and MissingRecommended the Google rich result
+// properties it lacks as dotted paths, Warnings the types and properties
+// schema.org does not define and Mismatches the values that do not appear in
+// the page's visible text.
+type StructuredData struct {
+	Format             string         `json:"format"`
+	Types              []string       `json:"types,omitempty"`
+	Data               map[string]any `json:"data,omitempty"`
+	Errors             []string       `json:"errors,omitempty"`
+	Missing            []string       `json:"missing,omitempty"`
+	MissingRecommended []string       `json:"missing_recommended,omitempty"`
+	Warnings           []string       `json:"warnings,omitempty"`
+	Mismatches         []string       `json:"mismatches,omitempty"`
 }
 
+// PhoneNumber is a phone number found on a page. Display is the number as
+// the page writes it and E164 its international form. Region is empty for
+// calling codes outside the bundled numbering plans, and Confidence, from 0
+// to 1, grows with the evidence that the digits are a phone number.
+type PhoneNumber struct {
+	Display    string  `json:"display"`
+	E164       string  `json:"e164"`
+	Region     string  `json:"region,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Email is a valid, lowercased email address found on a page. Role is set
+// for role addresses such as info@ or noreply@ and empty for personal ones.
+type Email struct {
+	Address string `json:"address"`
+	Role    string `json:"role,omitempty"`
+}
+
+// Roles of role addresses used in Email.Role
+const (
+	EmailRoleGeneral = "general"
+	EmailRoleSales   = "sales"
+	EmailRoleSupport = "support"
+	EmailRoleNoReply = "noreply"
+	EmailRoleAdmin   = "admin"
+	EmailRoleJobs    = "jobs"
+	EmailRolePress   = "press"
+	EmailRoleBilling = "billing"
+	EmailRoleLegal   = "legal"
+)
+
+// Structured data formats used in StructuredData.Format
+const (
+	FormatJSONLD    = "json-ld"
+	FormatMicrodata = "microdata"
+	FormatRDFa      = "rdfa"
+)
+
 // Link represents a hyperlink from one page to another
 type Link struct {
 	ToURL      string `json:"to_url"`
 	AnchorText string `json:"anchor_text"`
+	Rel        string `json:"rel,omitempty"`
+	Scheme     string `json:"scheme,omitempty"`
+	Scope      string `json:"scope,omitempty"`
 }
 
+// Link scopes used in Link.Scope, relative to the linking page
+const (
+	LinkInternal  = "internal"
+	LinkSubdomain = "subdomain"
+	LinkExternal  = "external"
+)
+
+// Document types used in Page.DocumentType
+const (
+	DocumentHTML = "html"
+	DocumentPDF  = "pdf"
+	DocumentDOCX = "docx"
+	DocumentText = "text"
+)
+
 // CrawlResult contains the results of a crawl operation
 type CrawlResult struct {
-	Domain       string    `json:"domain"`
-	Pages        []Page    `json:"pages"`
-	TotalPages   int       `json:"total_pages"`
-	CrawlTime    time.Time `json:"crawl_time"`
-	ErrorCount   int       `json:"error_count"`
-	Subdomains   []string  `json:"subdomains"`
+	CrawlID    string    `json:"crawl_id,omitempty"`
+	Domain     string    `json:"domain"`
+	Pages      []Page    `json:"pages"`
+	TotalPages int       `json:"total_pages"`
+	CrawlTime  time.Time `json:"crawl_time"`
+	ErrorCount int       `json:"error_count"`
+	Subdomains []string  `json:"subdomains"`
+
+	// Duplicates maps URLs whose body matched an earlier page to that page's URL
+	Duplicates map[string]string `json:"duplicates,omitempty"`
+	// TruncatedPatterns lists URL templates that hit the per-pattern budget
+	TruncatedPatterns []PathPattern `json:"truncated_patterns,omitempty"`
+
+	// Sitemaps lists the sitemap documents that were read
+	Sitemaps    []string       `json:"sitemaps,omitempty"`
+	SitemapURLs []SitemapEntry `json:"sitemap_urls,omitempty"`
+	// URLSources records how each in-scope URL was discovered: "sitemap",
+	// "link" or "both". It is only filled when sitemaps were read.
+	URLSources map[string]string `json:"url_sources,omitempty"`
+
+	// BlockedURLs lists in-scope URLs that robots policy kept out of the crawl
+	BlockedURLs []BlockedURL `json:"blocked_urls,omitempty"`
+
+	// QuarantinedURLs were discovered but look like crawler traps, such as
+	// endless calendars or faceted navigation, and were not crawled
+	QuarantinedURLs []QuarantinedURL `json:"quarantined_urls,omitempty"`
+
+	// PreviousCrawlID is the baseline of an incremental crawl; RemovedURLs
+	// lists its pages that are gone or no longer reachable
+	PreviousCrawlID string   `json:"previous_crawl_id,omitempty"`
+	RemovedURLs     []string `json:"removed_urls,omitempty"`
+
+	// CollapsedURLs maps canonical URLs to the other spellings they were
+	// found under, such as with tracking parameters or a default port
+	CollapsedURLs map[string][]string `json:"collapsed_urls,omitempty"`
+
+	// SubdomainReport details every subdomain in Subdomains
+	SubdomainReport []Subdomain `json:"subdomain_report,omitempty"`
+
+	// WARCFiles lists the archives the crawl was written to
+	WARCFiles []string `json:"warc_files,omitempty"`
+
+	// HostStats reports how each host was paced during the crawl
+	HostStats []HostStats `json:"host_stats,omitempty"`
+
+	// LinkChecks holds the outlinks of a list crawl whose status was checked
+	// without crawling them
+	LinkChecks []LinkCheck `json:"link_checks,omitempty"`
+}
+
+// URL discovery sources used in CrawlResult.URLSources
+const (
+	SourceSitemap = "sitemap"
+	SourceLink    = "link"
+	SourceBoth    = "both"
+)
+
+// Subdomain is a host below the crawled domain and how it was discovered
+type Subdomain struct {
+	Host      string   `json:"host"`
+	Sources   []string `json:"sources"`
+	Resolves  bool     `json:"resolves"`
+	Addresses []string `json:"addresses,omitempty"`
+	Crawled   bool     `json:"crawled"`
+}
+
+// Subdomain discovery sources used in Subdomain.Sources
+const (
+	SubdomainSourceLink     = "link"
+	SubdomainSourceSitemap  = "sitemap"
+	SubdomainSourceCSP      = "csp"
+	SubdomainSourceCORS     = "cors"
+	SubdomainSourceTLS      = "tls-san"
+	SubdomainSourceWordlist = "wordlist"
+)
+
+// HostStats summarises requests to one host. Rate is the host's request rate
+// per second at the end of the crawl, or 0 when it was unlimited.
+type HostStats struct {
+	Host      string  `json:"host"`
+	Requests  int     `json:"requests"`
+	Retries   int     `json:"retries"`
+	Throttled int     `json:"throttled"`
+	Backoffs  int     `json:"backoffs"`
+	Errors    int     `json:"errors"`
+	Rate      float64 `json:"rate"`
+}
+
+// RedirectHop is one redirect response in a chain
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// Timings breaks down how long a page took to fetch, in milliseconds
+type Timings struct {
+	DNS     float64 `json:"dns_ms"`
+	Connect float64 `json:"connect_ms"`
+	TLS     float64 `json:"tls_ms"`
+	TTFB    float64 `json:"ttfb_ms"`
+	Total   float64 `json:"total_ms"`
+}
+
+// Page change states used in Page.Change
+const (
+	ChangeNew       = "new"
+	ChangeChanged   = "changed"
+	ChangeUnchanged = "unchanged"
+	ChangeRemoved   = "removed"
+)
+
+// BlockedURL is a URL that was not crawled because of a robots rule
+type BlockedURL struct {
+	URL     string `json:"url"`
+	Source  string `json:"source"`
+	Rule    string `json:"rule"`
+	FoundOn string `json:"found_on,omitempty"`
+}
+
+// Robots policy sources used in BlockedURL.Source
+const (
+	BlockedByRobotsTxt  = "robots.txt"
+	BlockedByMetaRobots = "meta-robots"
+	BlockedByXRobotsTag = "x-robots-tag"
+	BlockedByRel        = "rel-nofollow"
+)
+
+// QuarantinedURL is a URL left uncrawled because it looks like a crawler trap
+type QuarantinedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Crawler trap heuristics used in QuarantinedURL.Reason
+const (
+	TrapRepeatingPath = "repeating-path"
+	TrapPathDepth     = "path-depth"
+	TrapQueryParams   = "query-params"
+	TrapCounter       = "counter"
+	TrapNearDuplicate = "near-duplicate"
+)
+
+// LinkCheck is the status of a link target that was requested but not crawled.
+// Error is set instead of StatusCode when the request failed.
+type LinkCheck struct {
+	URL        string   `json:"url"`
+	StatusCode int      `json:"status_code,omitempty"`
+	FinalURL   string   `json:"final_url,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	FoundOn    []string `json:"found_on"`
+}
+
+// SitemapEntry is a URL listed in an XML sitemap
+type SitemapEntry struct {
+	URL      string  `json:"url"`
+	LastMod  string  `json:"lastmod,omitempty"`
+	Priority float64 `json:"priority,omitempty"`
+	Sitemap  string  `json:"sitemap"`
+}
+
+// PathPattern summarises the crawl budget spent on one URL template of a host
+type PathPattern struct {
+	Host    string `json:"host"`
+	Pattern string `json:"pattern"`
+	Crawled int    `json:"crawled"`
+	Skipped int    `json:"skipped"`
 }
 
 // SEOReport represents a comprehensive SEO analysis report
 type SEOReport struct {
-	Domain           string            `json:"domain"`
-	GeneratedAt      time.Time         `json:"generated_at"`
-	ExecutiveSummary ExecutiveSummary  `json:"executive_summary"`
-	Scores           OverallScores     `json:"scores"`
-	KeyFindings      []Finding         `json:"key_findings"`
-	Recommendations  []Recommendation  `json:"recommendations"`
-	DataSources      []string          `json:"data_sources"`
+	Domain           string           `json:"domain"`
+	GeneratedAt      time.Time        `json:"generated_at"`
+	ExecutiveSummary ExecutiveSummary `json:"executive_summary"`
+	Scores           OverallScores    `json:"scores"`
+	KeyFindings      []Finding        `json:"key_findings"`
+	Recommendations  []Recommendation `json:"recommendations"`
+	DataSources      []string         `json:"data_sources"`
 }
 
 // ExecutiveSummary provides high-level SEO insights
@@ -83,4 +404,4 @@ type Recommendation struct {
 	Impact      string `json:"impact"`
 	Effort      string `json:"effort"`
 	Description string `json:"description"`
-}
\ No newline at end of file
+}
@@ -7,11 +7,13 @@ import (
 	"strings"
 	
 	"github.com/amosWeiskopf/crawlsmith/internal/models"
+	"github.com/amosWeiskopf/crawlsmith/pkg/utils"
 )
 
 // Analyzer performs SEO and content analysis
 type Analyzer struct {
 	config *Config
+	canon  *utils.Canonicalizer
 }
 
 // Config holds analyzer configuration
@@ -22,6 +24,9 @@ type Config struct {
 	AnalyzeContent     bool
 	AnalyzeTechnical   bool
 	AnalyzePerformance bool
+	
+	// Canonical should match the crawler's options so links and pages line up
+	Canonical utils.CanonicalOptions
 }
 
 // New creates a new Analyzer instance
@@ -33,12 +38,13 @@ func New() *Analyzer {
 			AnalyzeTechnical:   true,
 			AnalyzePerformance: true,
 		},
+		canon: utils.NewCanonicalizer(utils.CanonicalOptions{}),
 	}
 }
 
 // NewWithConfig creates an Analyzer with custom configuration
 func NewWithConfig(config *Config) *Analyzer {
-	return &Analyzer{config: config}
+	return &Analyzer{config: config, canon: utils.NewCanonicalizer(config.Canonical)}
 }
 
 // Analyze performs comprehensive SEO analysis on crawl results
@@ -96,9 +102,14 @@ func (a *Analyzer) calculatePageRank(crawlResult *models.CrawlResult) {
 	inboundLinks := make(map[string][]string)
 	
 	for _, page := range crawlResult.Pages {
+		from := a.canonical(page.URL)
 		for _, link := range page.Links {
-			linkGraph[page.URL] = append(linkGraph[page.URL], link.ToURL)
-			inboundLinks[link.ToURL] = append(inboundLinks[link.ToURL], page.URL)
+			if link.Scheme != "" && link.Scheme != "http" && link.Scheme != "https" {
+				continue
+			}
+			to := a.canonical(link.ToURL)
+			linkGraph[from] = append(linkGraph[from], to)
+			inboundLinks[to] = append(inboundLinks[to], from)
 		}
 	}
 	
@@ -106,7 +117,7 @@ func (a *Analyzer) calculatePageRank(crawlResult *models.CrawlResult) {
 	pageCount := float64(len(crawlResult.Pages))
 	pageRank := make(map[string]float64)
 	for _, page := range crawlResult.Pages {
-		pageRank[page.URL] = 1.0 / pageCount
+		pageRank[a.canonical(page.URL)] = 1.0 / pageCount
 	}
 	
 	// Iterate PageRank calculation
@@ -115,15 +126,16 @@ func (a *Analyzer) calculatePageRank(crawlResult *models.CrawlResult) {
 		
 		for _, page := range crawlResult.Pages {
 			rank := (1.0 - dampingFactor) / pageCount
+			key := a.canonical(page.URL)
 			
-			for _, inbound := range inboundLinks[page.URL] {
+			for _, inbound := range inboundLinks[key] {
 				outboundCount := float64(len(linkGraph[inbound]))
 				if outboundCount > 0 {
 					rank += dampingFactor * pageRank[inbound] / outboundCount
 				}
 			}
 			
-			newPageRank[page.URL] = rank
+			newPageRank[key] = rank
 		}
 		
 		pageRank = newPageRank
@@ -131,10 +143,18 @@ func (a *Analyzer) calculatePageRank(crawlResult *models.CrawlResult) {
 	
 	// Update pages with PageRank scores
 	for i := range crawlResult.Pages {
-		crawlResult.Pages[i].PageRank = pageRank[crawlResult.Pages[i].URL]
+		crawlResult.Pages[i].PageRank = pageRank[a.canonical(crawlResult.Pages[i].URL)]
 	}
 }
 
+// canonical returns the canonical form of rawURL, or rawURL if it cannot be parsed
+func (a *Analyzer) canonical(rawURL string) string {
+	if canonical, err := a.canon.Canonicalize(rawURL); err == nil {
+		return canonical
+	}
+	return rawURL
+}
+
 // analyzeContent evaluates content quality
 func (a *Analyzer) analyzeContent(crawlResult *models.CrawlResult) float64 {
 	score := 0.0
@@ -284,10 +304,10 @@ func (a *Analyzer) calculateOverallScore(scores models.OverallScores) float64 {
 func (a *Analyzer) generateFindings(crawlResult *models.CrawlResult) []models.Finding {
 	findings := []models.Finding{}
 	
-	// Check for missing meta descriptions
+	// Check for missing meta descriptions; PDFs and other documents have none
 	missingDesc := 0
 	for _, page := range crawlResult.Pages {
-		if page.MetaDescription == "" {
+		if page.MetaDescription == "" && !isDocument(page) {
 			missingDesc++
 		}
 	}
@@ -332,9 +352,331 @@ func (a *Analyzer) generateFindings(crawlResult *models.CrawlResult) []models.Fi
 		})
 	}
 	
+	findings = append(findings, a.analyzeSitemapCoverage(crawlResult)...)
+	findings = append(findings, a.analyzeResponses(crawlResult)...)
+	findings = append(findings, a.analyzeURLVariants(crawlResult)...)
+	findings = append(findings, a.analyzeTraps(crawlResult)...)
+	findings = append(findings, a.analyzeLinkChecks(crawlResult)...)
+	findings = append(findings, a.analyzeOnPage(crawlResult)...)
+	findings = append(findings, a.analyzeStructuredData(crawlResult)...)
+	
+	return findings
+}
+
+// analyzeSitemapCoverage compares sitemap URLs with what the crawl found
+func (a *Analyzer) analyzeSitemapCoverage(crawlResult *models.CrawlResult) []models.Finding {
+	if len(crawlResult.SitemapURLs) == 0 {
+		return nil
+	}
+	findings := []models.Finding{}
+	
+	// Orphans are listed in a sitemap but not linked from any crawled page
+	var orphans []string
+	for _, entry := range crawlResult.SitemapURLs {
+		if crawlResult.URLSources[entry.URL] == models.SourceSitemap {
+			orphans = append(orphans, entry.URL)
+		}
+	}
+	if len(orphans) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Orphan Pages",
+			Description: fmt.Sprintf("%d sitemap URLs are not linked from any crawled page", len(orphans)),
+			Severity:    "medium",
+			Details:     sampleURLs(orphans),
+		})
+	}
+	
+	inSitemap := make(map[string]bool, len(crawlResult.SitemapURLs))
+	for _, entry := range crawlResult.SitemapURLs {
+		inSitemap[entry.URL] = true
+	}
+	
+	var nonIndexable, redirecting []string
+	for _, page := range crawlResult.Pages {
+		if !inSitemap[page.URL] {
+			continue
+		}
+		if !page.Indexable {
+			nonIndexable = append(nonIndexable, page.URL)
+		}
+		if page.FinalURL != "" && page.FinalURL != page.URL {
+			redirecting = append(redirecting, page.URL)
+		}
+	}
+	if len(nonIndexable) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Non-Indexable Sitemap URLs",
+			Description: fmt.Sprintf("%d sitemap URLs cannot be indexed", len(nonIndexable)),
+			Severity:    "high",
+			Details:     sampleURLs(nonIndexable),
+		})
+	}
+	if len(redirecting) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Redirecting Sitemap URLs",
+			Description: fmt.Sprintf("%d sitemap URLs redirect to another URL", len(redirecting)),
+			Severity:    "medium",
+			Details:     sampleURLs(redirecting),
+		})
+	}
+	
 	return findings
 }
 
+// slowResponseMs is the time to first byte above which a page counts as slow
+const slowResponseMs = 1000
+
+// analyzeResponses flags redirect chains, redirect loops and slow responses
+func (a *Analyzer) analyzeResponses(crawlResult *models.CrawlResult) []models.Finding {
+	findings := []models.Finding{}
+	
+	var chains, loops, slow []string
+	for _, page := range crawlResult.Pages {
+		switch {
+		case page.RedirectLoop:
+			loops = append(loops, page.URL)
+		case len(page.RedirectChain) > 1:
+			chains = append(chains, fmt.Sprintf("%s (%d hops)", page.URL, len(page.RedirectChain)))
+		}
+		if page.Timings != nil && page.Timings.TTFB > slowResponseMs {
+			slow = append(slow, fmt.Sprintf("%s (%.0fms)", page.URL, page.Timings.TTFB))
+		}
+	}
+	
+	if len(loops) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Redirect Loops",
+			Description: fmt.Sprintf("%d URLs redirect in a loop and never resolve", len(loops)),
+			Severity:    "critical",
+			Details:     sampleURLs(loops),
+		})
+	}
+	if len(chains) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Redirect Chains",
+			Description: fmt.Sprintf("%d URLs pass through more than one redirect", len(chains)),
+			Severity:    "medium",
+			Details:     sampleURLs(chains),
+		})
+	}
+	if len(slow) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Performance",
+			Type:        "Slow Responses",
+			Description: fmt.Sprintf("%d pages took over %dms to start responding", len(slow), slowResponseMs),
+			Severity:    "medium",
+			Details:     sampleURLs(slow),
+		})
+	}
+	
+	return findings
+}
+
+// analyzeURLVariants flags pages that are linked under several spellings
+func (a *Analyzer) analyzeURLVariants(crawlResult *models.CrawlResult) []models.Finding {
+	if len(crawlResult.CollapsedURLs) == 0 {
+		return nil
+	}
+	
+	canonicals := make([]string, 0, len(crawlResult.CollapsedURLs))
+	for canonical, variants := range crawlResult.CollapsedURLs {
+		canonicals = append(canonicals, fmt.Sprintf("%s (%d variants)", canonical, len(variants)))
+	}
+	sort.Strings(canonicals)
+	
+	return []models.Finding{{
+		Category:    "Technical",
+		Type:        "URL Variants",
+		Description: fmt.Sprintf("%d URLs are linked under more than one address", len(canonicals)),
+		Severity:    "low",
+		Details:     sampleURLs(canonicals),
+	}}
+}
+
+// analyzeTraps reports URL spaces the crawler quarantined as likely traps
+func (a *Analyzer) analyzeTraps(crawlResult *models.CrawlResult) []models.Finding {
+	if len(crawlResult.QuarantinedURLs) == 0 {
+		return nil
+	}
+	
+	byReason := make(map[string]int)
+	var urls []string
+	for _, q := range crawlResult.QuarantinedURLs {
+		byReason[q.Reason]++
+		urls = append(urls, q.URL)
+	}
+	reasons := make([]string, 0, len(byReason))
+	for reason, count := range byReason {
+		reasons = append(reasons, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(reasons)
+	
+	return []models.Finding{{
+		Category:    "Technical",
+		Type:        "Crawler Traps",
+		Description: fmt.Sprintf("%d URLs look like crawler traps and were not crawled (%s)", len(urls), strings.Join(reasons, ", ")),
+		Severity:    "medium",
+		Details:     sampleURLs(urls),
+	}}
+}
+
+// analyzeLinkChecks flags outlinks of a list crawl that failed or returned an
+// error status
+func (a *Analyzer) analyzeLinkChecks(crawlResult *models.CrawlResult) []models.Finding {
+	var broken []string
+	for _, check := range crawlResult.LinkChecks {
+		switch {
+		case check.Error != "":
+			broken = append(broken, fmt.Sprintf("%s (unreachable)", check.URL))
+		case check.StatusCode >= 400:
+			broken = append(broken, fmt.Sprintf("%s (%d)", check.URL, check.StatusCode))
+		}
+	}
+	if len(broken) == 0 {
+		return nil
+	}
+	
+	return []models.Finding{{
+		Category:    "Technical",
+		Type:        "Broken Outlinks",
+		Description: fmt.Sprintf("%d linked URLs are unreachable or return an error", len(broken)),
+		Severity:    "high",
+		Details:     sampleURLs(broken),
+	}}
+}
+
+// analyzeOnPage flags HTML pages without an H1 or a viewport and images
+// without alt text
+func (a *Analyzer) analyzeOnPage(crawlResult *models.CrawlResult) []models.Finding {
+	findings := []models.Finding{}
+	
+	var noH1, noViewport, missingAlt []string
+	for _, page := range crawlResult.Pages {
+		if page.OnPage == nil || page.StatusCode != 200 {
+			continue
+		}
+		hasH1 := false
+		for _, h := range page.OnPage.Headings {
+			hasH1 = hasH1 || h.Level == 1
+		}
+		if !hasH1 {
+			noH1 = append(noH1, page.URL)
+		}
+		if page.OnPage.Viewport == "" {
+			noViewport = append(noViewport, page.URL)
+		}
+		if page.OnPage.ImagesMissingAlt > 0 {
+			missingAlt = append(missingAlt, fmt.Sprintf("%s (%d)", page.URL, page.OnPage.ImagesMissingAlt))
+		}
+	}
+	
+	if len(noH1) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Content",
+			Type:        "Missing H1",
+			Description: fmt.Sprintf("%d pages have no H1 heading", len(noH1)),
+			Severity:    "medium",
+			Details:     sampleURLs(noH1),
+		})
+	}
+	if len(noViewport) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Missing Viewport",
+			Description: fmt.Sprintf("%d pages do not declare a viewport and render poorly on mobile", len(noViewport)),
+			Severity:    "medium",
+			Details:     sampleURLs(noViewport),
+		})
+	}
+	if len(missingAlt) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Content",
+			Type:        "Images Missing Alt Text",
+			Description: fmt.Sprintf("%d pages have images without an alt attribute", len(missingAlt)),
+			Severity:    "low",
+			Details:     sampleURLs(missingAlt),
+		})
+	}
+	
+	return findings
+}
+
+// analyzeStructuredData flags structured data that cannot be read, lacks
+// properties Google requires for rich results, or describes something the
+// page does not show
+func (a *Analyzer) analyzeStructuredData(crawlResult *models.CrawlResult) []models.Finding {
+	findings := []models.Finding{}
+	
+	var invalid, missing, mismatched []string
+	for _, page := range crawlResult.Pages {
+		var errors, required, mismatches int
+		for _, item := range page.StructuredData {
+			errors += len(item.Errors)
+			required += len(item.Missing)
+			mismatches += len(item.Mismatches)
+		}
+		if errors > 0 {
+			invalid = append(invalid, fmt.Sprintf("%s (%d)", page.URL, errors))
+		}
+		if required > 0 {
+			missing = append(missing, fmt.Sprintf("%s (%d)", page.URL, required))
+		}
+		if mismatches > 0 {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%d)", page.URL, mismatches))
+		}
+	}
+	
+	if len(invalid) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Invalid Structured Data",
+			Description: fmt.Sprintf("%d pages have structured data that cannot be parsed or has no schema.org type", len(invalid)),
+			Severity:    "high",
+			Details:     sampleURLs(invalid),
+		})
+	}
+	if len(missing) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Technical",
+			Type:        "Missing Required Structured Data Properties",
+			Description: fmt.Sprintf("%d pages have structured data missing properties required for rich results", len(missing)),
+			Severity:    "high",
+			Details:     sampleURLs(missing),
+		})
+	}
+	if len(mismatched) > 0 {
+		findings = append(findings, models.Finding{
+			Category:    "Content",
+			Type:        "Structured Data Not Matching Content",
+			Description: fmt.Sprintf("%d pages have structured data naming things that do not appear on the page", len(mismatched)),
+			Severity:    "medium",
+			Details:     sampleURLs(mismatched),
+		})
+	}
+	
+	return findings
+}
+
+// isDocument reports whether page is a PDF, Office or text file rather than
+// an HTML page
+func isDocument(page models.Page) bool {
+	return page.DocumentType != "" && page.DocumentType != models.DocumentHTML
+}
+
+// sampleURLs formats up to five URLs for a finding's details
+func sampleURLs(urls []string) string {
+	const limit = 5
+	if len(urls) <= limit {
+		return strings.Join(urls, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(urls[:limit], ", "), len(urls)-limit)
+}
+
 // generateRecommendations creates actionable recommendations based on findings
 func (a *Analyzer) generateRecommendations(findings []models.Finding) []models.Recommendation {
 	recommendations := []models.Recommendation{}
@@ -376,6 +718,141 @@ func (a *Analyzer) generateRecommendations(findings []models.Finding) []models.R
 				Effort:      "medium",
 				Description: "Add more valuable, relevant content to pages with less than 300 words",
 			}
+		case "Orphan Pages":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Technical",
+				Action:      "Link to orphan pages",
+				Impact:      "medium",
+				Effort:      "medium",
+				Description: "Add internal links to sitemap URLs that no crawled page links to, or remove them from the sitemap",
+			}
+		case "Non-Indexable Sitemap URLs":
+			rec = models.Recommendation{
+				Priority:    "high",
+				Category:    "Technical",
+				Action:      "Clean up sitemap",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Only list URLs in the sitemap that return 200 and are meant to be indexed",
+			}
+		case "Redirecting Sitemap URLs":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Technical",
+				Action:      "Update redirected sitemap URLs",
+				Impact:      "low",
+				Effort:      "low",
+				Description: "Replace redirecting URLs in the sitemap with their final destinations",
+			}
+		case "Redirect Loops":
+			rec = models.Recommendation{
+				Priority:    "critical",
+				Category:    "Technical",
+				Action:      "Break redirect loops",
+				Impact:      "high",
+				Effort:      "low",
+				Description: "Fix redirect rules so every URL ends on a page that returns 200",
+			}
+		case "Redirect Chains":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Technical",
+				Action:      "Shorten redirect chains",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Redirect straight to the final URL and update internal links to point at it",
+			}
+		case "URL Variants":
+			rec = models.Recommendation{
+				Priority:    "low",
+				Category:    "Technical",
+				Action:      "Link to one address per page",
+				Impact:      "low",
+				Effort:      "low",
+				Description: "Use the canonical URL in internal links and declare it with rel=canonical so tracking parameters and alternate spellings do not split ranking signals",
+			}
+		case "Crawler Traps":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Technical",
+				Action:      "Close off infinite URL spaces",
+				Impact:      "medium",
+				Effort:      "medium",
+				Description: "Stop linking endless calendar, filter and session URLs, or mark them nofollow and disallow them in robots.txt, so crawl budget goes to real pages",
+			}
+		case "Broken Outlinks":
+			rec = models.Recommendation{
+				Priority:    "high",
+				Category:    "Technical",
+				Action:      "Fix broken links",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Update or remove links that point at missing or failing pages",
+			}
+		case "Missing H1":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Content",
+				Action:      "Add an H1 heading",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Give every page one H1 that states its topic",
+			}
+		case "Missing Viewport":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Technical",
+				Action:      "Declare a viewport",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: `Add <meta name="viewport" content="width=device-width, initial-scale=1"> so pages render at device width`,
+			}
+		case "Images Missing Alt Text":
+			rec = models.Recommendation{
+				Priority:    "low",
+				Category:    "Content",
+				Action:      "Add alt text to images",
+				Impact:      "low",
+				Effort:      "low",
+				Description: "Describe informative images in their alt attribute and give decorative ones an empty alt",
+			}
+		case "Invalid Structured Data":
+			rec = models.Recommendation{
+				Priority:    "high",
+				Category:    "Technical",
+				Action:      "Fix invalid structured data",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Make JSON-LD valid JSON with a schema.org @context and give every item an @type, so search engines can read it",
+			}
+		case "Missing Required Structured Data Properties":
+			rec = models.Recommendation{
+				Priority:    "high",
+				Category:    "Technical",
+				Action:      "Complete structured data",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Add the properties Google requires for each rich result type, such as offers, review or aggregateRating on a Product",
+			}
+		case "Structured Data Not Matching Content":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Content",
+				Action:      "Align structured data with page content",
+				Impact:      "medium",
+				Effort:      "low",
+				Description: "Mark up only what visitors can see: names and headlines in structured data should match the page",
+			}
+		case "Slow Responses":
+			rec = models.Recommendation{
+				Priority:    "medium",
+				Category:    "Performance",
+				Action:      "Reduce server response time",
+				Impact:      "medium",
+				Effort:      "medium",
+				Description: "Add caching or optimize the backend for pages with a slow time to first byte",
+			}
 		default:
 			continue
 		}
@@ -0,0 +1,223 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+	"github.com/amosWeiskopf/crawlsmith/pkg/warc"
+)
+
+// archiveSoftware is recorded in the warcinfo record of every WARC file
+const archiveSoftware = "CrawlSmith"
+
+// openArchive starts the WARC files of a crawl in opts.WARCPath
+func openArchive(opts Options, host string) (*warc.Writer, error) {
+	prefix := strings.NewReplacer(":", "_", "/", "_").Replace(strings.ToLower(host))
+	return warc.NewWriter(opts.WARCPath, prefix, opts.WARCMaxSize, archiveSoftware)
+}
+
+// archiveFetch writes the request of a fetch followed by every response it
+// got, redirects included. Redirect bodies are discarded by the HTTP client,
+// so only their headers are kept; body is the final response body as it
+// came over the wire. The values of the secret request headers are redacted.
+func archiveFetch(w *warc.Writer, req *http.Request, resp *http.Response, body []byte, secret []string) error {
+	var responses []*http.Response
+	for r := resp; r != nil; r = r.Request.Response {
+		responses = append(responses, r)
+	}
+
+	records := make([]*warc.Record, 0, len(responses)+1)
+	request := &warc.Record{
+		Type:        warc.TypeRequest,
+		TargetURI:   req.URL.String(),
+		ContentType: warc.ContentTypeHTTPRequest,
+		Content:     requestBlock(req, secret),
+	}
+	records = append(records, request)
+	for i := len(responses) - 1; i >= 0; i-- {
+		r := responses[i]
+		var payload []byte
+		if r == resp {
+			payload = body
+		}
+		records = append(records, &warc.Record{
+			Type:        warc.TypeResponse,
+			ID:          warc.NewID(),
+			TargetURI:   r.Request.URL.String(),
+			ContentType: warc.ContentTypeHTTPResponse,
+			Content:     responseBlock(r, payload),
+		})
+	}
+	request.ConcurrentTo = records[1].ID
+
+	if err := w.Write(records...); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", req.URL, err)
+	}
+	return nil
+}
+
+// requestBlock serializes the request line and headers of req, redacting the
+// values of the secret headers
+func requestBlock(req *http.Request, secret []string) []byte {
+	header := req.Header.Clone()
+	for _, name := range secret {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.URL.Host)
+	header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// responseBlock serializes the status line and headers of resp followed by
+// body, redacting the values of headers that carry session credentials
+func responseBlock(resp *http.Response, body []byte) []byte {
+	header := resp.Header.Clone()
+	for _, name := range secretResponseHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, redacted)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\r\n", resp.Proto, resp.Status)
+	header.Write(&b)
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
+
+// archivedFetch is a request record and the responses that followed it
+type archivedFetch struct {
+	request   *warc.Record
+	responses []*warc.Record
+}
+
+// ReadWARC rebuilds a crawl result from WARC files written by a crawl, so a
+// crawl can be analyzed again without going back to the network. Pages are
+// extracted with the default options.
+func ReadWARC(paths ...string) (*models.CrawlResult, error) {
+	var fetches []archivedFetch
+	for _, path := range paths {
+		var err error
+		if fetches, err = readArchive(path, fetches); err != nil {
+			return nil, err
+		}
+	}
+	if len(fetches) == 0 {
+		return nil, fmt.Errorf("no archived requests found in %s", strings.Join(paths, ", "))
+	}
+
+	opts := DefaultOptions()
+	opts.MaxPages = 0
+	opts.UseSitemaps = false
+	c, err := NewWithOptions(fetches[0].request.TargetURI, opts)
+	if err != nil {
+		return nil, err
+	}
+	run := c.newRun()
+	run.result.CrawlTime = fetches[0].request.Date
+	for _, f := range fetches {
+		u, err := url.Parse(f.request.TargetURI)
+		if err != nil {
+			continue
+		}
+		t := task{url: run.canon.URL(u)}
+		run.seen[t.url] = true
+		res, err := c.replayFetch(run, t, f)
+		if err != nil {
+			return nil, err
+		}
+		run.apply(res)
+	}
+	run.finish(true)
+	return run.result, nil
+}
+
+// readArchive appends the fetches recorded in one WARC file to fetches
+func readArchive(path string, fetches []archivedFetch) ([]archivedFetch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WARC file: %w", err)
+	}
+	defer f.Close()
+
+	r, err := warc.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer r.Close()
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return fetches, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		switch {
+		case rec.Type == warc.TypeRequest:
+			fetches = append(fetches, archivedFetch{request: rec})
+		case rec.Type == warc.TypeResponse && len(fetches) > 0:
+			last := &fetches[len(fetches)-1]
+			last.responses = append(last.responses, rec)
+		}
+	}
+}
+
+// replayFetch turns an archived fetch back into the result the crawl got
+func (c *WebCrawler) replayFetch(run *crawlRun, t task, f archivedFetch) (fetchResult, error) {
+	if len(f.responses) == 0 {
+		return fetchResult{task: t, err: fmt.Errorf("no archived response for %s", t.url)}, nil
+	}
+
+	// Link the responses the way the HTTP client does, so redirect chains
+	// come out the same as during the crawl
+	var resp *http.Response
+	var raw []byte
+	for _, rec := range f.responses {
+		u, err := url.Parse(rec.TargetURI)
+		if err != nil {
+			return fetchResult{}, fmt.Errorf("invalid archived URL %q: %w", rec.TargetURI, err)
+		}
+		r, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.Content)), nil)
+		if err != nil {
+			return fetchResult{}, fmt.Errorf("failed to parse archived response for %s: %w", rec.TargetURI, err)
+		}
+		raw, err = io.ReadAll(r.Body)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fetchResult{}, fmt.Errorf("failed to read archived response for %s: %w", rec.TargetURI, err)
+		}
+		r.Body.Close()
+		r.Request = &http.Request{Method: http.MethodGet, URL: u, Response: resp}
+		resp = r
+	}
+
+	decoded, err := decodeBody(resp.Header.Get("Content-Encoding"), bytes.NewReader(raw))
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("reading %s: %w", t.url, err)}, nil
+	}
+	defer decoded.Close()
+	body, err := io.ReadAll(io.LimitReader(decoded, maxBodySize))
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("reading %s: %w", t.url, err)}, nil
+	}
+
+	page, hash := c.buildPage(context.Background(), run, t, resp, body, int64(len(raw)))
+	page.CrawledAt = f.responses[len(f.responses)-1].Date
+	return fetchResult{task: t, page: page, hash: hash}, nil
+}
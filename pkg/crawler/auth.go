@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/amosWeiskopf/crawlsmith/pkg/extractor"
+)
+
+// AuthOptions holds the credentials used to crawl sites behind a login. They
+// are sent with requests, including those of pages rendered with JavaScript,
+// but never written to the journal, WARC files or the crawl result.
+type AuthOptions struct {
+	Hosts      []HostAuth // Headers and credentials per host
+	CookieFile string     // Netscape cookies.txt loaded into the cookie jar
+	Login      *FormLogin // Form submitted before the crawl starts
+}
+
+// HostAuth is sent to Host and its subdomains. An empty Host means the host
+// of the start URL.
+type HostAuth struct {
+	Host     string
+	Headers  map[string]string // Extra request headers, such as an API key
+	Username string            // HTTP basic auth
+	Password string
+	Token    string // Bearer token; takes precedence over basic auth
+}
+
+// FormLogin fills in and submits a login form. The form's own fields, such as
+// CSRF tokens, are submitted along with the credentials and Fields.
+type FormLogin struct {
+	URL           string            // Page with the login form
+	UsernameField string            // Name of the username input; defaults to the first text or email input
+	PasswordField string            // Name of the password input; defaults to the first password input
+	Username      string            // Value of the username input
+	Password      string            // Value of the password input
+	Fields        map[string]string // Other fields to set
+	SuccessText   string            // Text the page after login must contain; empty only checks the status
+}
+
+// redacted replaces secret header values in archived requests
+const redacted = "[redacted]"
+
+// SetAuth replaces the credentials used by later crawls and loads the cookie
+// file, if any. Resume does not restore credentials, so set them again on a
+// resumed crawler.
+func (c *WebCrawler) SetAuth(auth AuthOptions) error {
+	var jar http.CookieJar
+	if auth.CookieFile != "" || auth.Login != nil {
+		j, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		if auth.CookieFile != "" {
+			if err := loadCookies(j, auth.CookieFile); err != nil {
+				return err
+			}
+		}
+		jar = j
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.Auth = auth
+	c.client.Jar = jar
+	return nil
+}
+
+// loadCookies adds the unexpired cookies of a Netscape cookies.txt file to jar
+func loadCookies(jar http.CookieJar, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open cookie file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return fmt.Errorf("invalid cookie file %s: line %d has %d fields, want 7", path, n, len(fields))
+		}
+
+		domain, subdomains, secure := fields[0], strings.EqualFold(fields[1], "TRUE"), strings.EqualFold(fields[3], "TRUE")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+			if cookie.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		host := strings.TrimPrefix(domain, ".")
+		if subdomains {
+			cookie.Domain = host
+		}
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read cookie file: %w", err)
+	}
+	return nil
+}
+
+// authorize adds the headers and credentials configured for the request's
+// host. Headers set for another host, which the HTTP client copies onto
+// redirects, are removed first.
+func (c *WebCrawler) authorize(req *http.Request, auth AuthOptions) {
+	if len(auth.Hosts) == 0 {
+		return
+	}
+	req.Header.Del("Authorization")
+	for _, h := range auth.Hosts {
+		for name := range h.Headers {
+			req.Header.Del(name)
+		}
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	for _, h := range auth.Hosts {
+		if !c.authHost(h, host) {
+			continue
+		}
+		for name, value := range h.Headers {
+			req.Header.Set(name, value)
+		}
+		switch {
+		case h.Token != "":
+			req.Header.Set("Authorization", "Bearer "+h.Token)
+		case h.Username != "" || h.Password != "":
+			req.SetBasicAuth(h.Username, h.Password)
+		}
+	}
+}
+
+// checkRedirect applies the redirect rules and authorizes every hop for its
+// own host, so credentials do not follow a redirect to another site
+func (c *WebCrawler) checkRedirect(req *http.Request, via []*http.Request) error {
+	if err := checkRedirect(req, via); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	auth := c.opts.Auth
+	c.mu.Unlock()
+	c.authorize(req, auth)
+	return nil
+}
+
+// authHost reports whether h applies to host
+func (c *WebCrawler) authHost(h HostAuth, host string) bool {
+	want := strings.ToLower(h.Host)
+	if want == "" {
+		want = strings.ToLower(c.startURL.Hostname())
+	}
+	return host == want || isSubdomainOf(host, want)
+}
+
+// secretHeaders lists the request headers whose values must not be archived
+func (a AuthOptions) secretHeaders() []string {
+	names := []string{"Authorization", "Proxy-Authorization", "Cookie"}
+	for _, h := range a.Hosts {
+		for name := range h.Headers {
+			names = append(names, http.CanonicalHeaderKey(name))
+		}
+	}
+	return names
+}
+
+// secretResponseHeaders are the response headers that carry session
+// credentials. They are left out of stored pages and redacted in archives.
+var secretResponseHeaders = []string{"Set-Cookie", "Set-Cookie2", "Authentication-Info", "Proxy-Authentication-Info"}
+
+// storedHeaders returns a copy of header without the secret response headers
+func storedHeaders(header http.Header) map[string][]string {
+	stored := header.Clone()
+	for _, name := range secretResponseHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
+
+// login submits the configured login form. The session cookies it receives
+// stay in the client's cookie jar for the rest of the crawl.
+func (c *WebCrawler) login(ctx context.Context, opts Options) error {
+	l := opts.Auth.Login
+	status, body, err := c.get(ctx, l.URL, opts, maxBodySize)
+	if err != nil {
+		return fmt.Errorf("failed to load login page: %w", err)
+	}
+	if status >= 400 {
+		return fmt.Errorf("failed to load login page: status %d", status)
+	}
+	body, _ = extractor.DecodeCharset(body, "")
+	forms, err := c.extractor.ExtractForms(string(body), l.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse login page: %w", err)
+	}
+
+	form := loginForm(forms)
+	if form == nil {
+		return fmt.Errorf("no login form found on %s", l.URL)
+	}
+
+	values := url.Values{}
+	usernameField, passwordField := l.UsernameField, l.PasswordField
+	for _, field := range form.Fields {
+		switch field.Type {
+		case "submit", "button", "image", "reset", "file", "checkbox", "radio":
+			// Buttons and boxes are only submitted when set in Fields
+			continue
+		case "password":
+			if passwordField == "" {
+				passwordField = field.Name
+			}
+		case "text", "email":
+			if usernameField == "" {
+				usernameField = field.Name
+			}
+		}
+		values.Set(field.Name, field.Value)
+	}
+	if usernameField != "" {
+		values.Set(usernameField, l.Username)
+	}
+	if passwordField != "" {
+		values.Set(passwordField, l.Password)
+	}
+	for name, value := range l.Fields {
+		values.Set(name, value)
+	}
+
+	method, action, payload := http.MethodPost, form.Action, values.Encode()
+	if form.Method == http.MethodGet {
+		method, action, payload = http.MethodGet, strings.SplitN(action, "?", 2)[0]+"?"+payload, ""
+	}
+	req, err := http.NewRequestWithContext(ctx, method, action, strings.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid login form action %q: %w", form.Action, err)
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	req.Header.Set("Referer", l.URL)
+	c.authorize(req, opts.Auth)
+
+	if err := c.hosts.wait(ctx, req.URL.Host); err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	c.hosts.observe(req.URL.Host, resp, err)
+	if err != nil {
+		return fmt.Errorf("failed to submit login form: %w", err)
+	}
+	defer resp.Body.Close()
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("failed to read login response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login rejected: status %d", resp.StatusCode)
+	}
+	page, _ = extractor.DecodeCharset(page, resp.Header.Get("Content-Type"))
+	if l.SuccessText != "" && !strings.Contains(string(page), l.SuccessText) {
+		return fmt.Errorf("login rejected: %s does not contain the success text", resp.Request.URL)
+	}
+	return nil
+}
+
+// loginForm picks the form with a password input, or the only form of a page
+func loginForm(forms []extractor.Form) *extractor.Form {
+	for i, form := range forms {
+		for _, field := range form.Fields {
+			if field.Type == "password" {
+				return &forms[i]
+			}
+		}
+	}
+	if len(forms) == 1 {
+		return &forms[0]
+	}
+	return nil
+}
@@ -0,0 +1,522 @@
+package crawler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cdpSettleTime is how long a page may keep running scripts after its load
+// event before the DOM is captured
+const cdpSettleTime = 500 * time.Millisecond
+
+var errCDPClosed = errors.New("devtools connection closed")
+
+// CDPRenderer renders pages in an externally started headless Chrome through
+// the Chrome DevTools Protocol. Each page is loaded in its own target from
+// the body the crawler already fetched, so Chrome never requests the page
+// itself.
+type CDPRenderer struct {
+	client  *cdpClient
+	timeout time.Duration
+
+	mu    sync.Mutex
+	fetch func(*http.Request) (*http.Response, error)
+}
+
+// NewCDPRenderer connects to a browser's DevTools endpoint. endpoint is
+// either its websocket URL (ws://127.0.0.1:9222/devtools/browser/<id>) or
+// the HTTP address of its debugging port (http://127.0.0.1:9222).
+func NewCDPRenderer(ctx context.Context, endpoint string, timeout time.Duration) (*CDPRenderer, error) {
+	wsURL, err := cdpWebSocketURL(ctx, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ws, err := dialWebSocket(ctx, wsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %w", err)
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	client := &cdpClient{
+		ws:       ws,
+		pending:  make(map[int64]chan cdpMessage),
+		sessions: make(map[string]chan cdpMessage),
+		done:     make(chan struct{}),
+	}
+	go client.readLoop()
+	return &CDPRenderer{client: client, timeout: timeout}, nil
+}
+
+// SetFetcher sends the requests pages make for scripts, styles, images and
+// XHR through fetch instead of letting Chrome connect on its own. The crawler
+// uses it to apply its user agent, credentials, rate limits, proxies and
+// address rules. WebSocket connections cannot be intercepted and are blocked
+// while a fetcher is set.
+func (r *CDPRenderer) SetFetcher(fetch func(*http.Request) (*http.Response, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fetch = fetch
+}
+
+// Render opens pageURL in a new tab, answering its request with body, waits
+// for it to load and settle, and returns its serialized DOM with any console
+// errors raised on the way
+func (r *CDPRenderer) Render(ctx context.Context, pageURL string, body []byte) (*Rendered, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	r.mu.Lock()
+	fetch := r.fetch
+	r.mu.Unlock()
+
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := r.client.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		r.client.call(closeCtx, "", "Target.closeTarget", map[string]any{"targetId": target.TargetID}, nil)
+	}()
+
+	var session struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := r.client.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &session); err != nil {
+		return nil, err
+	}
+	events := r.client.subscribe(session.SessionID)
+	defer r.client.unsubscribe(session.SessionID)
+
+	for _, domain := range []string{"Page", "Runtime", "Log"} {
+		if err := r.client.call(ctx, session.SessionID, domain+".enable", nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	// Pause every request so the page is served from body and everything
+	// else goes through the fetcher
+	patterns := map[string]any{"patterns": []any{map[string]any{"urlPattern": "*"}}}
+	if err := r.client.call(ctx, session.SessionID, "Fetch.enable", patterns, nil); err != nil {
+		return nil, err
+	}
+	if fetch != nil {
+		if err := r.client.call(ctx, session.SessionID, "Network.enable", nil, nil); err != nil {
+			return nil, err
+		}
+		blocked := map[string]any{"urls": []string{"ws://*", "wss://*"}}
+		if err := r.client.call(ctx, session.SessionID, "Network.setBlockedURLs", blocked, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Navigation only completes once its paused request is answered, so it
+	// runs alongside the event loop
+	navigated := make(chan error, 1)
+	go func() {
+		var nav struct {
+			ErrorText string `json:"errorText"`
+		}
+		err := r.client.call(ctx, session.SessionID, "Page.navigate", map[string]any{"url": pageURL}, &nav)
+		if err == nil && nav.ErrorText != "" {
+			err = fmt.Errorf("failed to load %s: %s", pageURL, nav.ErrorText)
+		}
+		navigated <- err
+	}()
+
+	// Requests still in flight once the DOM is captured are abandoned
+	var requests sync.WaitGroup
+	reqCtx, reqCancel := context.WithCancel(ctx)
+	defer func() {
+		reqCancel()
+		requests.Wait()
+	}()
+	served := false
+	rendered := &Rendered{}
+	var settle <-chan time.Time
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("rendering %s: %w", pageURL, ctx.Err())
+		case <-r.client.done:
+			return nil, errCDPClosed
+		case err := <-navigated:
+			if err != nil {
+				return nil, err
+			}
+			navigated = nil
+		case <-settle:
+			break wait
+		case ev := <-events:
+			switch {
+			case ev.Method == "Fetch.requestPaused":
+				var paused cdpPausedRequest
+				if err := json.Unmarshal(ev.Params, &paused); err != nil {
+					continue
+				}
+				if !served && paused.ResourceType == "Document" {
+					served = true
+					r.fulfill(ctx, session.SessionID, paused.RequestID, http.StatusOK,
+						http.Header{"Content-Type": {"text/html; charset=utf-8"}}, body)
+					continue
+				}
+				requests.Add(1)
+				go func() {
+					defer requests.Done()
+					r.serve(reqCtx, session.SessionID, paused, fetch)
+				}()
+			case ev.Method == "Page.loadEventFired" && settle == nil:
+				settle = time.After(cdpSettleTime)
+			default:
+				if msg := consoleError(ev); msg != "" {
+					rendered.ConsoleErrors = append(rendered.ConsoleErrors, msg)
+				}
+			}
+		}
+	}
+
+	var eval struct {
+		Result struct {
+			Value string `json:"value"`
+		} `json:"result"`
+	}
+	params := map[string]any{"expression": "document.documentElement.outerHTML", "returnByValue": true}
+	if err := r.client.call(ctx, session.SessionID, "Runtime.evaluate", params, &eval); err != nil {
+		return nil, err
+	}
+	rendered.HTML = eval.Result.Value
+
+	for len(events) > 0 {
+		if msg := consoleError(<-events); msg != "" {
+			rendered.ConsoleErrors = append(rendered.ConsoleErrors, msg)
+		}
+	}
+	return rendered, nil
+}
+
+// cdpPausedRequest is the part of a Fetch.requestPaused event needed to
+// answer it
+type cdpPausedRequest struct {
+	RequestID    string `json:"requestId"`
+	ResourceType string `json:"resourceType"`
+	Request      struct {
+		URL      string            `json:"url"`
+		Method   string            `json:"method"`
+		Headers  map[string]string `json:"headers"`
+		PostData string            `json:"postData"`
+	} `json:"request"`
+}
+
+// serve answers a request the page made with the response of fetch. Without
+// a fetcher, or for URLs that are not http(s), Chrome carries on by itself.
+// Requests the fetcher refuses fail as blocked.
+func (r *CDPRenderer) serve(ctx context.Context, sessionID string, paused cdpPausedRequest, fetch func(*http.Request) (*http.Response, error)) {
+	u, err := url.Parse(paused.Request.URL)
+	if fetch == nil || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		r.client.call(ctx, sessionID, "Fetch.continueRequest", map[string]any{"requestId": paused.RequestID}, nil)
+		return
+	}
+
+	var reqBody io.Reader
+	if paused.Request.PostData != "" {
+		reqBody = strings.NewReader(paused.Request.PostData)
+	}
+	req, err := http.NewRequestWithContext(ctx, paused.Request.Method, u.String(), reqBody)
+	if err == nil {
+		for name, value := range paused.Request.Headers {
+			// The crawler's client sets its own cookies and encodings
+			switch http.CanonicalHeaderKey(name) {
+			case "Cookie", "Accept-Encoding", "Content-Length", "Host":
+			default:
+				req.Header.Set(name, value)
+			}
+		}
+	}
+	var resp *http.Response
+	if err == nil {
+		resp, err = fetch(req)
+	}
+	if err != nil {
+		reason := "Failed"
+		if errors.Is(err, errBlockedAddress) {
+			reason = "BlockedByClient"
+		}
+		r.client.call(ctx, sessionID, "Fetch.failRequest", map[string]any{"requestId": paused.RequestID, "errorReason": reason}, nil)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		r.client.call(ctx, sessionID, "Fetch.failRequest", map[string]any{"requestId": paused.RequestID, "errorReason": "Failed"}, nil)
+		return
+	}
+	// The client already decoded the body and kept the cookies
+	header := resp.Header.Clone()
+	for _, name := range []string{"Content-Encoding", "Content-Length", "Set-Cookie"} {
+		header.Del(name)
+	}
+	r.fulfill(ctx, sessionID, paused.RequestID, resp.StatusCode, header, body)
+}
+
+// fulfill answers a paused request with a response
+func (r *CDPRenderer) fulfill(ctx context.Context, sessionID, requestID string, status int, header http.Header, body []byte) {
+	headers := make([]map[string]string, 0, len(header))
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, map[string]string{"name": name, "value": value})
+		}
+	}
+	r.client.call(ctx, sessionID, "Fetch.fulfillRequest", map[string]any{
+		"requestId":       requestID,
+		"responseCode":    status,
+		"responseHeaders": headers,
+		"body":            base64.StdEncoding.EncodeToString(body),
+	}, nil)
+}
+
+// Close disconnects from the browser; the browser itself keeps running
+func (r *CDPRenderer) Close() error {
+	return r.client.close()
+}
+
+// cdpWebSocketURL resolves an HTTP debugging address to the browser's
+// websocket URL through /json/version
+func cdpWebSocketURL(ctx context.Context, endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid renderer URL %q: %w", endpoint, err)
+	}
+	if u.Scheme == "ws" || u.Scheme == "wss" {
+		return endpoint, nil
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid renderer URL %q: scheme must be ws, wss, http or https", endpoint)
+	}
+
+	u.Path = "/json/version"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query browser version: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&version); err != nil {
+		return "", fmt.Errorf("failed to query browser version: %w", err)
+	}
+	if version.WebSocketDebuggerURL == "" {
+		return "", fmt.Errorf("browser at %s did not report a websocket URL", endpoint)
+	}
+	return version.WebSocketDebuggerURL, nil
+}
+
+// consoleError extracts the message of an error-level console, exception or
+// log event
+func consoleError(ev cdpMessage) string {
+	switch ev.Method {
+	case "Runtime.consoleAPICalled":
+		var p struct {
+			Type string `json:"type"`
+			Args []struct {
+				Value       any    `json:"value"`
+				Description string `json:"description"`
+			} `json:"args"`
+		}
+		if json.Unmarshal(ev.Params, &p) != nil || (p.Type != "error" && p.Type != "assert") {
+			return ""
+		}
+		parts := make([]string, 0, len(p.Args))
+		for _, arg := range p.Args {
+			if arg.Value != nil {
+				parts = append(parts, fmt.Sprint(arg.Value))
+			} else {
+				parts = append(parts, arg.Description)
+			}
+		}
+		return strings.Join(parts, " ")
+	case "Runtime.exceptionThrown":
+		var p struct {
+			ExceptionDetails struct {
+				Text      string `json:"text"`
+				Exception struct {
+					Description string `json:"description"`
+				} `json:"exception"`
+			} `json:"exceptionDetails"`
+		}
+		if json.Unmarshal(ev.Params, &p) != nil {
+			return ""
+		}
+		if d := p.ExceptionDetails.Exception.Description; d != "" {
+			return d
+		}
+		return p.ExceptionDetails.Text
+	case "Log.entryAdded":
+		var p struct {
+			Entry struct {
+				Level string `json:"level"`
+				Text  string `json:"text"`
+			} `json:"entry"`
+		}
+		if json.Unmarshal(ev.Params, &p) != nil || p.Entry.Level != "error" {
+			return ""
+		}
+		return p.Entry.Text
+	}
+	return ""
+}
+
+// cdpMessage is a DevTools command response or event
+type cdpMessage struct {
+	ID        int64           `json:"id,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// cdpClient multiplexes commands and per-session events over one browser
+// connection
+type cdpClient struct {
+	ws       *wsConn
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan cdpMessage
+	sessions map[string]chan cdpMessage
+	done     chan struct{}
+	closed   bool
+}
+
+// call sends a command and decodes its result into out, if out is non-nil
+func (c *cdpClient) call(ctx context.Context, sessionID, method string, params, out any) error {
+	reply := make(chan cdpMessage, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errCDPClosed
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	msg := map[string]any{"id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	if sessionID != "" {
+		msg["sessionId"] = sessionID
+	}
+	data, err := json.Marshal(msg)
+	if err == nil {
+		err = c.ws.writeMessage(wsText, data)
+	}
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	case <-c.done:
+		return errCDPClosed
+	case res := <-reply:
+		if res.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, res.Error.Message)
+		}
+		if out != nil && len(res.Result) > 0 {
+			if err := json.Unmarshal(res.Result, out); err != nil {
+				return fmt.Errorf("failed to decode %s result: %w", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// subscribe returns the event stream of a session. Events that arrive while
+// the buffer is full are dropped so one noisy page cannot stall the others.
+func (c *cdpClient) subscribe(sessionID string) <-chan cdpMessage {
+	ch := make(chan cdpMessage, 256)
+	c.mu.Lock()
+	c.sessions[sessionID] = ch
+	c.mu.Unlock()
+	return ch
+}
+
+// unsubscribe stops delivering a session's events
+func (c *cdpClient) unsubscribe(sessionID string) {
+	c.mu.Lock()
+	delete(c.sessions, sessionID)
+	c.mu.Unlock()
+}
+
+// readLoop routes responses to their callers and events to their sessions
+func (c *cdpClient) readLoop() {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		data, err := c.ws.readMessage()
+		if err != nil {
+			return
+		}
+		var msg cdpMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if msg.ID != 0 {
+			if reply, ok := c.pending[msg.ID]; ok {
+				delete(c.pending, msg.ID)
+				reply <- msg
+			}
+		} else if events, ok := c.sessions[msg.SessionID]; ok {
+			select {
+			case events <- msg:
+			default:
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// close shuts the connection and waits for the read loop to stop
+func (c *cdpClient) close() error {
+	err := c.ws.close()
+	<-c.done
+	return err
+}
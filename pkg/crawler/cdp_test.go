@@ -0,0 +1,132 @@
+package crawler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeBrowser answers the DevTools commands CDPRenderer sends, rendering
+// every page as html and logging one console error. Navigating pauses the
+// page's request and two script requests, and the answers to them are
+// recorded in answers by request ID.
+func fakeBrowser(html string, answers *sync.Map) *httptest.Server {
+	handler := func(ws *websocket.Conn) {
+		var navigation int64
+		for {
+			var msg struct {
+				ID        int64          `json:"id"`
+				Method    string         `json:"method"`
+				SessionID string         `json:"sessionId"`
+				Params    map[string]any `json:"params"`
+			}
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+
+			result := map[string]any{}
+			switch msg.Method {
+			case "Target.createTarget":
+				result["targetId"] = "T1"
+			case "Target.attachToTarget":
+				result["sessionId"] = "S1"
+			case "Runtime.evaluate":
+				result["result"] = map[string]any{"type": "string", "value": html}
+			case "Page.navigate":
+				// Navigation completes once the page's request is answered
+				navigation = msg.ID
+				for _, req := range []map[string]any{
+					{"requestId": "R1", "resourceType": "Document", "request": map[string]any{"url": msg.Params["url"], "method": "GET"}},
+					{"requestId": "R2", "resourceType": "Script", "request": map[string]any{"url": "http://10.0.0.1/admin.js", "method": "GET"}},
+					{"requestId": "R3", "resourceType": "Script", "request": map[string]any{"url": "http://cdn.example.com/app.js", "method": "GET"}},
+				} {
+					websocket.JSON.Send(ws, map[string]any{"method": "Fetch.requestPaused", "sessionId": "S1", "params": req})
+				}
+				continue
+			case "Fetch.fulfillRequest", "Fetch.failRequest", "Fetch.continueRequest":
+				id, _ := msg.Params["requestId"].(string)
+				msg.Params["method"] = msg.Method
+				answers.Store(id, msg.Params)
+			}
+			if err := websocket.JSON.Send(ws, map[string]any{"id": msg.ID, "result": result, "sessionId": msg.SessionID}); err != nil {
+				return
+			}
+
+			if msg.Method == "Fetch.fulfillRequest" && msg.Params["requestId"] == "R1" {
+				websocket.JSON.Send(ws, map[string]any{"id": navigation, "result": map[string]any{"frameId": "F1"}, "sessionId": "S1"})
+				websocket.JSON.Send(ws, map[string]any{
+					"method":    "Runtime.consoleAPICalled",
+					"sessionId": "S1",
+					"params":    map[string]any{"type": "error", "args": []any{map[string]any{"type": "string", "value": "boom"}}},
+				})
+				websocket.JSON.Send(ws, map[string]any{"method": "Page.loadEventFired", "sessionId": "S1", "params": map[string]any{}})
+			}
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/devtools/browser/1", websocket.Server{
+		Handler:   handler,
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+	})
+	var server *httptest.Server
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"webSocketDebuggerUrl": "ws" + strings.TrimPrefix(server.URL, "http") + "/devtools/browser/1",
+		})
+	})
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func TestCDPRenderer(t *testing.T) {
+	var answers sync.Map
+	browser := fakeBrowser(`<html><body><a href="/js">JS</a></body></html>`, &answers)
+	defer browser.Close()
+
+	r, err := NewCDPRenderer(context.Background(), browser.URL, 5*time.Second)
+	require.NoError(t, err)
+	defer r.Close()
+
+	// The page is served from the fetched body and its scripts go through
+	// the fetcher, which refuses the internal address
+	r.SetFetcher(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Hostname() == "10.0.0.1" {
+			return nil, fmt.Errorf("%w: %s", errBlockedAddress, req.URL.Host)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/javascript"}, "Set-Cookie": {"session=secret"}},
+			Body:       io.NopCloser(strings.NewReader("run()")),
+		}, nil
+	})
+	rendered, err := r.Render(context.Background(), "http://example.com/", []byte("<p>fetched</p>"))
+	require.NoError(t, err)
+	assert.Contains(t, rendered.HTML, `href="/js"`)
+	assert.Equal(t, []string{"boom"}, rendered.ConsoleErrors)
+
+	answer := func(id string) map[string]any {
+		v, ok := answers.Load(id)
+		require.True(t, ok, "request %s was not answered", id)
+		return v.(map[string]any)
+	}
+	assert.Equal(t, "Fetch.fulfillRequest", answer("R1")["method"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("<p>fetched</p>")), answer("R1")["body"])
+	assert.Equal(t, "Fetch.failRequest", answer("R2")["method"])
+	assert.Equal(t, "BlockedByClient", answer("R2")["errorReason"])
+	assert.Equal(t, "Fetch.fulfillRequest", answer("R3")["method"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("run()")), answer("R3")["body"])
+	assert.NotContains(t, fmt.Sprint(answer("R3")["responseHeaders"]), "secret")
+}
@@ -0,0 +1,1049 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+	"github.com/amosWeiskopf/crawlsmith/pkg/extractor"
+	"github.com/amosWeiskopf/crawlsmith/pkg/utils"
+	"github.com/amosWeiskopf/crawlsmith/pkg/warc"
+)
+
+// maxBodySize caps how much of a response body is read per page
+const maxBodySize = 10 << 20
+
+// WebCrawler is the concurrent HTTP implementation of Crawler
+type WebCrawler struct {
+	startURL  *url.URL
+	opts      Options
+	excludes  []*regexp.Regexp
+	client    *http.Client
+	hosts     *hostScheduler
+	extractor *extractor.Extractor
+	renderer  Renderer
+	resolver  *net.Resolver
+	guard     *addressGuard
+	proxies   *proxyPool
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	onPage    func(*models.Page)
+	onError   func(string, error)
+	onSkip    func(string, string)
+	crawlID   string
+	mu        sync.Mutex
+}
+
+// task is a single URL scheduled for fetching
+type task struct {
+	url      string
+	depth    int
+	previous *models.Page
+	check    bool // Only the status of a list crawl's outlink is wanted
+}
+
+// fetchResult is what a worker hands back to the dispatcher
+type fetchResult struct {
+	task task
+	page *models.Page
+	hash string
+	err  error
+}
+
+// crawlRun holds the state of a single Crawl invocation
+type crawlRun struct {
+	seed       *url.URL
+	opts       Options
+	excludes   []*regexp.Regexp
+	budget     *pathBudget
+	traps      *trapDetector
+	seen       map[string]bool
+	scheduled  int
+	hashes     map[string]string
+	listed     []*url.URL
+	hostRobots map[string]*robotsRules
+	loadRobots func(u *url.URL) *robotsRules
+	checks     map[string]*models.LinkCheck
+	subdomains map[string]map[string]bool
+	addresses  map[string][]string
+	linked     map[string]bool
+	inSitemap  map[string]bool
+	blocked    map[string]models.BlockedURL
+	trapped    []models.QuarantinedURL
+	previous   *previousCrawl
+	report     []models.Subdomain
+	renderer   Renderer
+	fetched    map[string]bool
+	pages      int
+	hosts      map[string]bool
+	removed    []string
+	discard    bool
+	events     func(Event)
+	canon      *utils.Canonicalizer
+	variants   map[string]map[string]bool
+	archive    *warc.Writer
+	frontier   *frontier
+	result     *models.CrawlResult
+}
+
+// New creates a crawler for startURL using the default options
+func New(startURL string, maxPerPath, maxPathTypes int) (*WebCrawler, error) {
+	opts := DefaultOptions()
+	opts.MaxPerPath = maxPerPath
+	opts.MaxPathTypes = maxPathTypes
+	return NewWithOptions(startURL, opts)
+}
+
+// NewWithOptions creates a crawler for startURL with custom options
+func NewWithOptions(startURL string, opts Options) (*WebCrawler, error) {
+	u, err := parseStartURL(startURL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxWorkers <= 0 {
+		opts.MaxWorkers = 1
+	}
+
+	client := &http.Client{
+		Timeout: time.Duration(opts.Timeout) * time.Second,
+	}
+	c := &WebCrawler{
+		startURL:  u,
+		client:    client,
+		hosts:     newHostScheduler(limitFor(opts.RequestsPerSec)),
+		extractor: extractor.New(),
+	}
+	client.CheckRedirect = c.checkRedirect
+	if opts.StoragePath != "" {
+		c.crawlID = newCrawlID(u.Host, time.Now())
+	}
+	patterns := opts.ExcludePatterns
+	opts.ExcludePatterns = nil
+	c.opts = opts
+	for _, p := range patterns {
+		c.AddExcludePattern(p)
+	}
+	if err := c.SetNetwork(opts.Network); err != nil {
+		return nil, err
+	}
+	if err := c.SetAuth(opts.Auth); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Crawl starts the crawling process
+func (c *WebCrawler) Crawl() (*models.CrawlResult, error) {
+	return c.CrawlWithContext(context.Background())
+}
+
+// CrawlWithContext crawls from the start URL until the frontier is exhausted,
+// a limit is reached or ctx is cancelled. On cancellation the pages crawled so
+// far are returned together with the context error. When StoragePath is set
+// the crawl is journaled to disk and a later call, or Resume, continues it.
+func (c *WebCrawler) CrawlWithContext(ctx context.Context) (*models.CrawlResult, error) {
+	return c.crawl(ctx, false, nil, nil)
+}
+
+// crawl runs a crawl, passing its events to the registered hooks and send.
+// With discard set, pages are only emitted and not kept in the result. A
+// non-nil list limits the crawl to those URLs.
+func (c *WebCrawler) crawl(ctx context.Context, discard bool, send func(Event), list []*url.URL) (result *models.CrawlResult, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	run := c.newRun()
+	run.discard = run.discard || discard
+	if list != nil {
+		run.startList(list)
+	}
+	c.hosts.resetStats()
+	if run.opts.EnableJS && run.renderer == nil {
+		if run.opts.RendererURL == "" {
+			return nil, fmt.Errorf("JavaScript rendering requires a renderer URL or SetRenderer")
+		}
+		run.renderer, err = NewCDPRenderer(ctx, run.opts.RendererURL, time.Duration(run.opts.Timeout)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		defer run.renderer.Close()
+	}
+	if cdp, ok := run.renderer.(*CDPRenderer); ok {
+		cdp.SetFetcher(c.renderFetch(run.opts))
+	}
+	c.mu.Lock()
+	proxies := c.proxies
+	c.mu.Unlock()
+	if proxies != nil && proxies.check(ctx, c.dial) == 0 {
+		return nil, fmt.Errorf("none of the %d proxies accepts connections", len(proxies.proxies))
+	}
+	if run.opts.Auth.Login != nil {
+		if err := c.login(ctx, run.opts); err != nil {
+			return nil, err
+		}
+	}
+	if run.opts.FollowRobotsTxt {
+		run.hostRobots = make(map[string]*robotsRules)
+		run.loadRobots = func(u *url.URL) *robotsRules {
+			return c.hostRobots(ctx, run.opts, u)
+		}
+	}
+
+	var replay []journalRecord
+	if run.opts.StoragePath != "" {
+		manifest := crawlManifest{
+			ID:        c.crawlID,
+			StartURL:  c.startURL.String(),
+			Options:   run.opts,
+			StartedAt: run.result.CrawlTime,
+		}
+		run.frontier, replay, err = openFrontier(run.opts.StoragePath, manifest, run.opts.CheckpointInterval)
+		if err != nil {
+			return nil, err
+		}
+		run.result.CrawlID = c.crawlID
+		run.result.CrawlTime = run.frontier.manifest.StartedAt
+		defer func() {
+			if cerr := run.frontier.close(err == nil); err == nil {
+				err = cerr
+			}
+		}()
+	}
+	if run.opts.Incremental {
+		if err := c.loadBaseline(run); err != nil {
+			return nil, err
+		}
+	}
+	if run.opts.UseSitemaps {
+		run.result.SitemapURLs = c.discoverSitemaps(ctx, run)
+	}
+	if run.listed == nil {
+		c.probeSubdomains(ctx, run)
+	}
+	queue := run.restore(replay)
+	if run.opts.WARCPath != "" {
+		run.archive, err = openArchive(run.opts, c.startURL.Host)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			run.result.WARCFiles = run.archive.Files()
+			if cerr := run.archive.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("failed to close WARC file: %w", cerr)
+			}
+		}()
+	}
+	// Journaled pages were reported by the session that fetched them
+	run.events = c.emitter(send)
+
+	tasks := make(chan task)
+	results := make(chan fetchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < run.opts.MaxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				results <- c.fetch(ctx, run, t)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	err = run.dispatch(ctx, queue, tasks, results)
+	close(tasks)
+	for range results {
+		// Drain in-flight fetches so workers can exit
+	}
+
+	if ctx.Err() == nil && run.listed == nil {
+		run.report = c.subdomainReport(ctx, run)
+	}
+	run.finish(err == nil)
+	run.result.HostStats = c.hosts.stats()
+	return run.result, err
+}
+
+// CrawlID identifies the persisted crawl; it is empty when StoragePath is unset
+func (c *WebCrawler) CrawlID() string {
+	return c.crawlID
+}
+
+// SetRateLimit sets the requests per second limit; zero or less disables it
+func (c *WebCrawler) SetRateLimit(requestsPerSecond int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.RequestsPerSec = requestsPerSecond
+	c.hosts.setBase(limitFor(requestsPerSecond))
+}
+
+// SetMaxDepth sets the maximum crawl depth
+func (c *WebCrawler) SetMaxDepth(depth int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.MaxDepth = depth
+}
+
+// SetUserAgent sets the user agent string
+func (c *WebCrawler) SetUserAgent(userAgent string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.UserAgent = userAgent
+}
+
+// AddExcludePattern adds a URL pattern to exclude from crawling. Patterns are
+// regular expressions; anything that fails to compile is matched literally.
+func (c *WebCrawler) AddExcludePattern(pattern string) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = regexp.MustCompile(regexp.QuoteMeta(pattern))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.ExcludePatterns = append(c.opts.ExcludePatterns, pattern)
+	c.excludes = append(c.excludes, re)
+}
+
+// EnableJavaScript enables JavaScript rendering (requires headless browser)
+func (c *WebCrawler) EnableJavaScript(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.EnableJS = enabled
+}
+
+// newRun snapshots the crawler settings into fresh per-crawl state
+func (c *WebCrawler) newRun() *crawlRun {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	opts := c.opts
+	opts.ExcludePatterns = append([]string(nil), c.opts.ExcludePatterns...)
+
+	seed := *c.startURL
+	run := &crawlRun{
+		seed:       &seed,
+		opts:       opts,
+		excludes:   append([]*regexp.Regexp(nil), c.excludes...),
+		budget:     newPathBudget(opts.MaxPerPath, opts.MaxPathTypes),
+		traps:      newTrapDetector(opts.Traps),
+		seen:       make(map[string]bool),
+		hashes:     make(map[string]string),
+		subdomains: make(map[string]map[string]bool),
+		addresses:  make(map[string][]string),
+		linked:     make(map[string]bool),
+		inSitemap:  make(map[string]bool),
+		blocked:    make(map[string]models.BlockedURL),
+		fetched:    make(map[string]bool),
+		hosts:      make(map[string]bool),
+		canon:      utils.NewCanonicalizer(opts.Canonical),
+		variants:   make(map[string]map[string]bool),
+		discard:    opts.DiscardPages,
+		result: &models.CrawlResult{
+			Domain:    c.startURL.Hostname(),
+			CrawlTime: time.Now(),
+		},
+	}
+	if opts.EnableJS {
+		run.renderer = c.renderer
+	}
+	return run
+}
+
+// restore admits the seed and sitemap URLs, or the listed URLs of a list
+// crawl, then replays journaled fetches through the normal admission rules,
+// returning the URLs that still have to be fetched
+func (r *crawlRun) restore(replay []journalRecord) []task {
+	if r.listed != nil {
+		return r.admitList()
+	}
+	var queue []task
+	r.linked[r.canon.URL(r.seed)] = true
+	if t, ok := r.admit(r.seed, 0, nil); ok {
+		queue = append(queue, t)
+	}
+	for _, entry := range r.result.SitemapURLs {
+		u, err := url.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+		r.inSitemap[entry.URL] = true
+		if t, ok := r.admit(u, 1, nil); ok {
+			queue = append(queue, t)
+		}
+	}
+	for _, u := range r.probedRoots() {
+		if t, ok := r.admit(u, 1, nil); ok {
+			queue = append(queue, t)
+		}
+	}
+	if len(replay) == 0 {
+		return queue
+	}
+
+	done := make(map[string]bool, len(replay))
+	for _, rec := range replay {
+		res := fetchResult{task: task{url: rec.URL, depth: rec.Depth}, page: rec.Page, hash: rec.Hash}
+		if rec.Error != "" {
+			res.err = errors.New(rec.Error)
+		}
+		done[rec.URL] = true
+		queue = append(queue, r.apply(res)...)
+	}
+
+	pending := queue[:0]
+	for _, t := range queue {
+		if !done[t.url] {
+			pending = append(pending, t)
+		}
+	}
+	return pending
+}
+
+// dispatch feeds the worker pool from the frontier and folds results back in.
+// It is the only goroutine touching the run state, so no locking is needed.
+func (r *crawlRun) dispatch(ctx context.Context, queue []task, tasks chan<- task, results <-chan fetchResult) error {
+	inFlight := 0
+	for len(queue) > 0 || inFlight > 0 {
+		var out chan<- task
+		var next task
+		if len(queue) > 0 {
+			out = tasks
+			next = queue[0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- next:
+			queue = queue[1:]
+			inFlight++
+		case res := <-results:
+			inFlight--
+			if ctx.Err() != nil {
+				// Leave fetches cut short by cancellation out of the journal
+				return ctx.Err()
+			}
+			next, err := r.handle(res)
+			if err != nil {
+				return err
+			}
+			queue = append(queue, next...)
+		}
+	}
+
+	return nil
+}
+
+// loadBaseline loads the crawl an incremental run is compared against. The
+// choice is stored in the manifest so a resumed crawl keeps the same baseline.
+func (c *WebCrawler) loadBaseline(run *crawlRun) error {
+	if run.frontier == nil {
+		return fmt.Errorf("incremental crawls require a storage path")
+	}
+
+	id := run.frontier.manifest.Previous
+	if id == "" {
+		var err error
+		id, err = findPreviousCrawl(run.opts.StoragePath, c.startURL.Host, c.crawlID)
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			// First crawl of this host: every page is new
+			run.previous = &previousCrawl{}
+			return nil
+		}
+		run.frontier.manifest.Previous = id
+		if err := run.frontier.saveManifest(); err != nil {
+			return err
+		}
+	}
+
+	prev, err := loadPreviousCrawl(run.opts.StoragePath, id)
+	if err != nil {
+		return err
+	}
+	run.previous = prev
+	run.result.PreviousCrawlID = id
+	return nil
+}
+
+// handle journals a fetch result, when the crawl is persisted, and applies it
+func (r *crawlRun) handle(res fetchResult) ([]task, error) {
+	if res.page != nil && !res.task.check {
+		res.page.ClickDepth = r.clickDepth(res.task)
+	}
+	if r.frontier != nil {
+		rec := journalRecord{URL: res.task.url, Depth: res.task.depth, Hash: res.hash, Page: res.page}
+		if res.err != nil {
+			rec.Error = res.err.Error()
+			rec.Page = nil
+		}
+		if err := r.frontier.record(rec); err != nil {
+			return nil, err
+		}
+	}
+	return r.apply(res), nil
+}
+
+// apply records a fetch result and returns newly discovered tasks
+func (r *crawlRun) apply(res fetchResult) []task {
+	if res.task.check {
+		r.recordCheck(res)
+		return nil
+	}
+	r.fetched[res.task.url] = true
+	if res.err != nil {
+		r.result.ErrorCount++
+		r.emit(Event{Type: EventError, URL: res.task.url, Depth: res.task.depth, Err: res.err})
+		return nil
+	}
+	r.traps.observe(res.page)
+
+	// Catch-all handlers and soft 404s serve the same body under many URLs;
+	// keep the first one and record the rest as duplicates, whose links are
+	// still followed as relative links may lead elsewhere. Redirects are
+	// always kept so they can be audited.
+	duplicate := false
+	if res.hash != "" && (res.page.FinalURL == "" || res.page.FinalURL == res.task.url) {
+		if original, ok := r.hashes[res.hash]; ok {
+			if r.result.Duplicates == nil {
+				r.result.Duplicates = make(map[string]string)
+			}
+			r.result.Duplicates[res.task.url] = original
+			r.skip(res.task.url, res.task.depth, SkipDuplicate)
+			duplicate = true
+		} else {
+			r.hashes[res.hash] = res.task.url
+		}
+	}
+
+	if !duplicate {
+		r.pages++
+		if u, err := url.Parse(res.page.URL); err == nil {
+			r.hosts[strings.ToLower(u.Hostname())] = true
+		}
+		if res.page.Change == models.ChangeRemoved {
+			r.removed = append(r.removed, res.page.URL)
+		}
+		if !r.discard {
+			r.result.Pages = append(r.result.Pages, *res.page)
+		}
+		r.emit(Event{Type: EventPage, URL: res.task.url, Depth: res.task.depth, Page: res.page})
+	}
+	if r.listed != nil {
+		return r.checkLinks(res.page)
+	}
+	r.headerSubdomains(res.page.Headers)
+
+	var next []task
+	from, _ := url.Parse(res.task.url)
+	for _, link := range res.page.Links {
+		u, err := url.Parse(link.ToURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		r.addSubdomain(u.Hostname(), models.SubdomainSourceLink)
+		if r.inScope(u) {
+			r.linked[r.canon.URL(u)] = true
+		}
+		if r.opts.FollowRobotsTxt {
+			switch {
+			case !res.page.Followable:
+				r.block(u, res.task.depth+1, nofollowSource(res.page), "nofollow", res.task.url)
+				continue
+			case isNofollow(link.Rel):
+				r.block(u, res.task.depth+1, models.BlockedByRel, `rel="`+link.Rel+`"`, res.task.url)
+				continue
+			}
+		}
+		if t, ok := r.admit(u, res.task.depth+1, from); ok {
+			next = append(next, t)
+		}
+	}
+
+	return next
+}
+
+// admit decides whether u, linked from the page at from or found elsewhere
+// when from is nil, should be scheduled and marks it as seen
+func (r *crawlRun) admit(u *url.URL, depth int, from *url.URL) (task, bool) {
+	if u == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return task{}, false
+	}
+	if depth > r.opts.MaxDepth || (r.opts.MaxPages > 0 && r.scheduled >= r.opts.MaxPages) {
+		return task{}, false
+	}
+	if !r.inScope(u) {
+		return task{}, false
+	}
+
+	key := r.canon.URL(u)
+	r.collapse(u, key)
+	if r.seen[key] {
+		return task{}, false
+	}
+	r.seen[key] = true
+
+	for _, re := range r.excludes {
+		if re.MatchString(key) {
+			r.skip(key, depth, SkipExcluded)
+			return task{}, false
+		}
+	}
+	if robots := r.robotsFor(u); robots != nil && !robots.allowed(u) {
+		r.blocked[key] = models.BlockedURL{URL: key, Source: models.BlockedByRobotsTxt, Rule: robots.rule(u)}
+		r.skip(key, depth, models.BlockedByRobotsTxt)
+		return task{}, false
+	}
+	if reason, detail := r.traps.check(u, from); reason != "" && depth > 0 {
+		r.quarantine(key, depth, reason, detail)
+		return task{}, false
+	}
+	if !r.budget.allow(u.Host, PathPattern(u)) {
+		r.skip(key, depth, SkipPathLimit)
+		return task{}, false
+	}
+
+	r.scheduled++
+	delete(r.blocked, key)
+	t := task{url: key, depth: depth}
+	if r.previous != nil {
+		t.previous = r.previous.pages[key]
+	}
+	return t, true
+}
+
+// block records an unfollowed link unless the URL was already reached some
+// other way. A link that is followed elsewhere later is unblocked by admit.
+func (r *crawlRun) block(u *url.URL, depth int, source, rule, foundOn string) {
+	if (u.Scheme != "http" && u.Scheme != "https") || !r.inScope(u) {
+		return
+	}
+	key := r.canon.URL(u)
+	if r.seen[key] {
+		return
+	}
+	if _, ok := r.blocked[key]; !ok {
+		r.blocked[key] = models.BlockedURL{URL: key, Source: source, Rule: rule, FoundOn: foundOn}
+		r.skip(key, depth, source)
+	}
+}
+
+// collapse remembers that u was canonicalized to key when the two differ.
+// Fragments are ignored; they never reach the server.
+func (r *crawlRun) collapse(u *url.URL, key string) {
+	raw := *u
+	raw.Fragment, raw.RawFragment = "", ""
+	if s := raw.String(); s != key {
+		if r.variants[key] == nil {
+			r.variants[key] = make(map[string]bool)
+		}
+		r.variants[key][s] = true
+	}
+}
+
+// collapsedURLs lists the raw URLs seen for each canonical URL that had any
+func (r *crawlRun) collapsedURLs() map[string][]string {
+	if len(r.variants) == 0 {
+		return nil
+	}
+	collapsed := make(map[string][]string, len(r.variants))
+	for key, raws := range r.variants {
+		for raw := range raws {
+			collapsed[key] = append(collapsed[key], raw)
+		}
+		sort.Strings(collapsed[key])
+	}
+	return collapsed
+}
+
+// inScope reports whether u belongs to the site being crawled
+func (r *crawlRun) inScope(u *url.URL) bool {
+	if strings.EqualFold(u.Host, r.seed.Host) {
+		return true
+	}
+	return r.opts.IncludeSubdomains && isSubdomainOf(strings.ToLower(u.Hostname()), baseDomain(r.seed.Hostname()))
+}
+
+// finish fills in the summary fields of the result. complete reports whether
+// the frontier was exhausted rather than the crawl being cut short.
+func (r *crawlRun) finish(complete bool) {
+	r.result.TotalPages = r.pages
+	r.clickDepths()
+	r.result.TruncatedPatterns = r.budget.truncated()
+	for host := range r.subdomains {
+		r.result.Subdomains = append(r.result.Subdomains, host)
+	}
+	sort.Strings(r.result.Subdomains)
+	r.result.SubdomainReport = r.report
+	r.result.CollapsedURLs = r.collapsedURLs()
+	r.result.LinkChecks = r.linkChecks()
+
+	for _, b := range r.blocked {
+		r.result.BlockedURLs = append(r.result.BlockedURLs, b)
+	}
+	sort.Slice(r.result.BlockedURLs, func(i, j int) bool {
+		return r.result.BlockedURLs[i].URL < r.result.BlockedURLs[j].URL
+	})
+	r.result.QuarantinedURLs = r.trapped
+	sort.Slice(r.result.QuarantinedURLs, func(i, j int) bool {
+		return r.result.QuarantinedURLs[i].URL < r.result.QuarantinedURLs[j].URL
+	})
+	if r.previous != nil {
+		r.result.RemovedURLs = r.removedURLs(complete)
+	}
+
+	if len(r.inSitemap) > 0 {
+		r.result.URLSources = make(map[string]string, len(r.linked)+len(r.inSitemap))
+		for u := range r.linked {
+			r.result.URLSources[u] = models.SourceLink
+		}
+		for u := range r.inSitemap {
+			if r.linked[u] {
+				r.result.URLSources[u] = models.SourceBoth
+			} else {
+				r.result.URLSources[u] = models.SourceSitemap
+			}
+		}
+	}
+}
+
+// fetch downloads a single URL and extracts its content. Workers call it
+// concurrently, so it only reads the run's fixed settings.
+func (c *WebCrawler) fetch(ctx context.Context, run *crawlRun, t task) fetchResult {
+	if t.check {
+		return c.checkLink(ctx, run, t)
+	}
+	opts := run.opts
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return fetchResult{task: t, err: err}
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	setConditionalHeaders(req, t.previous)
+	c.authorize(req, opts.Auth)
+
+	// Retry while the server pushes back; the host scheduler spaces the
+	// attempts out
+	var resp *http.Response
+	var trace *fetchTrace
+	for attempt := 0; ; attempt++ {
+		if err := c.hosts.wait(ctx, req.URL.Host); err != nil {
+			return fetchResult{task: t, err: err}
+		}
+		trace = newFetchTrace()
+		// The cookie jar adds to the request's headers, so every attempt sends a copy
+		resp, err = c.client.Do(req.Clone(httptrace.WithClientTrace(ctx, trace.clientTrace())))
+		if !c.hosts.observe(req.URL.Host, resp, err) || attempt >= opts.MaxRetries || ctx.Err() != nil {
+			break
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+			resp.Body.Close()
+		}
+		c.hosts.retried(req.URL.Host)
+	}
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("fetching %s: %w", t.url, err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && t.previous != nil {
+		if run.archive != nil {
+			if err := archiveFetch(run.archive, req, resp, nil, opts.Auth.secretHeaders()); err != nil {
+				return fetchResult{task: t, err: err}
+			}
+		}
+		page := notModified(t.previous, resp)
+		page.Timings = trace.timings()
+		return fetchResult{task: t, page: page, hash: page.ContentHash}
+	}
+
+	// The archive keeps the body as it came over the wire
+	var raw bytes.Buffer
+	wire := &countingReader{r: resp.Body}
+	if run.archive != nil {
+		wire.r = io.TeeReader(resp.Body, &raw)
+	}
+	decoded, err := decodeBody(resp.Header.Get("Content-Encoding"), wire)
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("reading %s: %w", t.url, err)}
+	}
+	defer decoded.Close()
+	body, err := io.ReadAll(io.LimitReader(decoded, maxBodySize))
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("reading %s: %w", t.url, err)}
+	}
+	if run.archive != nil {
+		if err := archiveFetch(run.archive, req, resp, raw.Bytes(), opts.Auth.secretHeaders()); err != nil {
+			return fetchResult{task: t, err: err}
+		}
+	}
+
+	page, hash := c.buildPage(ctx, run, t, resp, body, wire.n)
+	page.Timings = trace.timings()
+	return fetchResult{task: t, page: page, hash: hash}
+}
+
+// buildPage describes a response whose decoded body is body and which took
+// wireSize bytes on the wire, extracting its content when it is HTML or a
+// supported document
+func (c *WebCrawler) buildPage(ctx context.Context, run *crawlRun, t task, resp *http.Response, body []byte, wireSize int64) (*models.Page, string) {
+	opts := run.opts
+	page := &models.Page{
+		URL:          t.url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		CrawledAt:    time.Now(),
+		StatusCode:   resp.StatusCode,
+		FinalURL:     resp.Request.URL.String(),
+		XRobotsTag:   headerDirectives(resp.Header, opts.UserAgent),
+
+		RedirectChain: redirectChain(resp),
+		Headers:       storedHeaders(resp.Header),
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: wireSize,
+		Compression:   resp.Header.Get("Content-Encoding"),
+	}
+	page.RedirectLoop = isRedirectLoop(page.RedirectChain)
+
+	var hash string
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		sum := sha256.Sum256(body)
+		page.ContentHash = hex.EncodeToString(sum[:])
+		docType := extractor.DocumentType(page.ContentType, page.FinalURL)
+		if docType == "" && isHTML(page.ContentType) {
+			docType = models.DocumentHTML
+		}
+		if docType == models.DocumentHTML || docType == models.DocumentText {
+			body, page.Charset = extractor.DecodeCharset(body, page.ContentType)
+		}
+		switch docType {
+		case models.DocumentHTML:
+			if doc, err := extractor.Parse(string(body), page.URL); err == nil {
+				if run.renderer != nil {
+					doc = c.render(ctx, run.renderer, page, body, doc)
+				}
+				c.extractPage(page, doc, opts)
+			}
+		case models.DocumentPDF, models.DocumentDOCX, models.DocumentText:
+			c.extractDocument(page, docType, body, opts)
+		}
+		if docType != "" {
+			page.DocumentType = docType
+			page.Fingerprint = fingerprint(page.Text)
+			hash = page.ContentHash
+		}
+	}
+	applyRobotsDirectives(page)
+	if opts.Incremental {
+		page.Change = changeStatus(t.previous, page)
+	}
+	return page, hash
+}
+
+// render runs the page's JavaScript and records how the rendered document
+// differs from raw, the parsed body. It returns the document to extract from,
+// which is raw when rendering fails.
+func (c *WebCrawler) render(ctx context.Context, renderer Renderer, page *models.Page, body []byte, raw *extractor.HTMLDocument) *extractor.HTMLDocument {
+	rendered, err := renderer.Render(ctx, page.URL, body)
+	if err != nil {
+		page.RenderError = err.Error()
+		return raw
+	}
+	page.RenderedHTML = rendered.HTML
+	page.ConsoleErrors = rendered.ConsoleErrors
+
+	doc, err := extractor.Parse(rendered.HTML, page.URL)
+	if err != nil {
+		return raw
+	}
+	page.RenderedOnlyLinks, page.RawOnlyLinks = linkDiff(linkURLs(raw.Links()), linkURLs(doc.Links()))
+	return doc
+}
+
+// linkURLs returns the targets of links
+func linkURLs(links []extractor.Link) []string {
+	urls := make([]string, 0, len(links))
+	for _, link := range links {
+		urls = append(urls, link.URL)
+	}
+	return urls
+}
+
+// get performs a rate-limited GET for crawler housekeeping files such as
+// robots.txt and sitemaps, reading at most limit bytes of the body
+func (c *WebCrawler) get(ctx context.Context, rawURL string, opts Options, limit int64) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	c.authorize(req, opts.Auth)
+
+	if err := c.hosts.wait(ctx, req.URL.Host); err != nil {
+		return 0, nil, err
+	}
+	resp, err := c.client.Do(req)
+	c.hosts.observe(req.URL.Host, resp, err)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// extractPage fills page with text, metadata, structured data, links and
+// contacts from a parsed HTML document
+func (c *WebCrawler) extractPage(page *models.Page, doc *extractor.HTMLDocument, opts Options) {
+	x := c.extractor.Extract(doc, extractor.ExtractOptions{
+		Contacts:    opts.ExtractContacts,
+		PhoneRegion: phoneRegion(opts, doc.URL),
+	})
+	page.MetaTitle = x.Title
+	page.MetaDescription = x.Description
+	page.MetaRobots = x.MetaRobots
+	page.OnPage = x.OnPage
+	page.StructuredData = x.StructuredData
+	page.SchemaTypes = x.SchemaTypes
+	page.Text = x.Text
+	addLinks(page, x.Links)
+	if x.Contacts != nil {
+		setContacts(page, x.Contacts)
+	}
+}
+
+// extractDocument fills page with the text, title, links and contacts of a
+// PDF, DOCX or plain text file. A document that fails to parse is kept
+// without content.
+func (c *WebCrawler) extractDocument(page *models.Page, docType string, body []byte, opts Options) {
+	doc, err := c.extractor.ExtractDocument(docType, body, page.URL)
+	if err != nil {
+		return
+	}
+	page.MetaTitle = doc.Title
+	page.Text = doc.Text
+	addLinks(page, doc.Links)
+
+	if opts.ExtractContacts {
+		u, _ := url.Parse(page.URL)
+		setContacts(page, c.extractor.ExtractContacts(doc.Text, doc.Text, phoneRegion(opts, u)))
+	}
+}
+
+// phoneRegion is the region national phone numbers on the page at u are read
+// in: Options.PhoneRegion, else the region of the host's country-code domain,
+// else the US
+func phoneRegion(opts Options, u *url.URL) string {
+	if opts.PhoneRegion != "" {
+		return opts.PhoneRegion
+	}
+	if u != nil {
+		if region := extractor.RegionForHost(u.Hostname()); region != "" {
+			return region
+		}
+	}
+	return "US"
+}
+
+// setContacts fills the contact fields of page
+func setContacts(page *models.Page, contacts *extractor.Contacts) {
+	page.Emails = contacts.Emails
+	page.EmailAddresses = contacts.EmailAddresses
+	page.Phones = contacts.Phones
+	page.PhoneNumbers = contacts.PhoneNumbers
+	page.WhatsApps = contacts.WhatsApps
+	page.XHandles = contacts.XHandles
+	page.LinkedIns = contacts.LinkedIns
+}
+
+// addLinks appends extracted links to page
+func addLinks(page *models.Page, links []extractor.Link) {
+	for _, link := range links {
+		page.Links = append(page.Links, models.Link{
+			ToURL:      link.URL,
+			AnchorText: link.AnchorText,
+			Rel:        link.Rel,
+			Scheme:     link.Scheme,
+			Scope:      link.Scope,
+		})
+	}
+}
+
+// parseStartURL validates the seed URL and gives it an explicit root path
+func parseStartURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("start URL is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid start URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid start URL %q: missing host", raw)
+	}
+	u.Host = strings.ToLower(u.Host)
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+// baseDomain strips a leading www. so subdomains are matched against the site root
+func baseDomain(host string) string {
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
+
+// isSubdomainOf reports whether host is a strict subdomain of domain
+func isSubdomainOf(host, domain string) bool {
+	return host != domain && strings.HasSuffix(host, "."+domain)
+}
+
+// isHTML reports whether a Content-Type header describes an HTML document
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	ct := strings.ToLower(contentType)
+	return strings.Contains(ct, "text/html") || strings.Contains(ct, "application/xhtml")
+}
+
+// limitFor converts a requests-per-second setting into a limiter rate
+func limitFor(requestsPerSecond int) rate.Limit {
+	if requestsPerSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(requestsPerSecond)
+}
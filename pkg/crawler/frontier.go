@@ -0,0 +1,241 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+const (
+	manifestFile = "manifest.json"
+	journalFile  = "journal.jsonl"
+
+	statusRunning   = "running"
+	statusCompleted = "completed"
+)
+
+// crawlManifest describes a persisted crawl so it can be resumed
+type crawlManifest struct {
+	ID        string    `json:"id"`
+	StartURL  string    `json:"start_url"`
+	Options   Options   `json:"options"`
+	StartedAt time.Time `json:"started_at"`
+	Status    string    `json:"status"`
+	Previous  string    `json:"previous,omitempty"`
+}
+
+// journalRecord is the outcome of one fetch. The journal is replayed through
+// the normal admission rules on resume, which rebuilds the queue, the
+// seen-set and the completed pages exactly as they were.
+type journalRecord struct {
+	URL   string       `json:"url"`
+	Depth int          `json:"depth"`
+	Hash  string       `json:"hash,omitempty"`
+	Page  *models.Page `json:"page,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// frontier is the disk-backed state of a single crawl under
+// <storage>/crawls/<crawl-id>
+type frontier struct {
+	dir             string
+	manifest        crawlManifest
+	journal         *os.File
+	checkpointEvery int
+	pending         int
+}
+
+// openFrontier creates the crawl directory, or reopens it when it already
+// exists, and returns the journal records written so far
+func openFrontier(storagePath string, manifest crawlManifest, checkpointEvery int) (*frontier, []journalRecord, error) {
+	dir := crawlDir(storagePath, manifest.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create crawl directory: %w", err)
+	}
+
+	if existing, err := readManifest(dir); err == nil {
+		manifest = *existing
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open crawl journal: %w", err)
+	}
+
+	records, end, err := readJournal(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	// Drop a record torn by a crash so new records start on a clean line
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to repair crawl journal: %w", err)
+	}
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to repair crawl journal: %w", err)
+	}
+
+	if checkpointEvery <= 0 {
+		checkpointEvery = 1
+	}
+	fr := &frontier{dir: dir, manifest: manifest, journal: f, checkpointEvery: checkpointEvery}
+	if err := fr.setStatus(statusRunning); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return fr, records, nil
+}
+
+// record appends a fetch outcome to the journal, syncing it to disk every
+// checkpointEvery records
+func (f *frontier) record(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal record: %w", err)
+	}
+	if _, err := f.journal.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write crawl journal: %w", err)
+	}
+
+	f.pending++
+	if f.pending >= f.checkpointEvery {
+		return f.checkpoint()
+	}
+	return nil
+}
+
+// checkpoint flushes the journal to stable storage
+func (f *frontier) checkpoint() error {
+	f.pending = 0
+	if err := f.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync crawl journal: %w", err)
+	}
+	return nil
+}
+
+// close checkpoints the journal and records whether the crawl finished
+func (f *frontier) close(completed bool) error {
+	err := f.checkpoint()
+	if cerr := f.journal.Close(); err == nil {
+		err = cerr
+	}
+	if completed {
+		if serr := f.setStatus(statusCompleted); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// setStatus rewrites the manifest atomically with a new status
+func (f *frontier) setStatus(status string) error {
+	f.manifest.Status = status
+	return f.saveManifest()
+}
+
+// saveManifest rewrites the manifest atomically
+func (f *frontier) saveManifest() error {
+	data, err := json.MarshalIndent(f.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode crawl manifest: %w", err)
+	}
+
+	tmp := filepath.Join(f.dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write crawl manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(f.dir, manifestFile)); err != nil {
+		return fmt.Errorf("failed to write crawl manifest: %w", err)
+	}
+	return nil
+}
+
+// readManifest loads the manifest of a persisted crawl
+func readManifest(dir string) (*crawlManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m crawlManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid crawl manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// readJournal decodes every complete record and returns the offset just past
+// the last one
+func readJournal(f *os.File) ([]journalRecord, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to read crawl journal: %w", err)
+	}
+
+	var records []journalRecord
+	var end int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read crawl journal: %w", err)
+		}
+
+		var rec journalRecord
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := json.Unmarshal(line, &rec); err != nil {
+				break
+			}
+			records = append(records, rec)
+		}
+		end += int64(len(line))
+	}
+
+	return records, end, nil
+}
+
+// crawlDir is where a crawl's state lives
+func crawlDir(storagePath, crawlID string) string {
+	return filepath.Join(storagePath, "crawls", crawlID)
+}
+
+// newCrawlID builds a sortable, filesystem-safe crawl identifier
+func newCrawlID(host string, now time.Time) string {
+	host = strings.NewReplacer(":", "_", "/", "_").Replace(strings.ToLower(host))
+	return fmt.Sprintf("%s-%s", host, now.UTC().Format("20060102T150405.000"))
+}
+
+// Resume reopens a persisted crawl so that the next Crawl call continues where
+// it stopped. Pages that were already fetched are not requested again.
+// Credentials and proxies are not saved with the crawl; restore them with
+// SetAuth and SetNetwork.
+func Resume(storagePath, crawlID string) (*WebCrawler, error) {
+	m, err := readManifest(crawlDir(storagePath, crawlID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load crawl %s: %w", crawlID, err)
+	}
+
+	opts := m.Options
+	opts.StoragePath = storagePath
+	c, err := NewWithOptions(m.StartURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.crawlID = m.ID
+	return c, nil
+}
@@ -0,0 +1,234 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+const (
+	// initialBackoff is the pause after the first failure in a row; it
+	// doubles with every further failure up to maxBackoff
+	initialBackoff = time.Second
+	maxBackoff     = time.Minute
+	// maxRetryAfter caps how long a Retry-After header can stall a host
+	maxRetryAfter = 5 * time.Minute
+	// recoverAfter healthy responses raise a throttled host's rate again
+	recoverAfter = 5
+	// minHostRate is the slowest a host is ever crawled, in requests/second
+	minHostRate = 0.1
+)
+
+// hostScheduler paces requests per host. Every host starts at the configured
+// rate; it is halved and the host paused whenever the server pushes back,
+// and it climbs back towards the configured rate while responses are healthy.
+type hostScheduler struct {
+	mu    sync.Mutex
+	base  rate.Limit
+	hosts map[string]*hostState
+}
+
+// hostState is the politeness state of a single host
+type hostState struct {
+	limiter     *rate.Limiter
+	ceiling     rate.Limit
+	pausedUntil time.Time
+	failures    int
+	healthy     int
+	stats       models.HostStats
+}
+
+// newHostScheduler creates a scheduler allowing base requests per second per host
+func newHostScheduler(base rate.Limit) *hostScheduler {
+	return &hostScheduler{base: base, hosts: make(map[string]*hostState)}
+}
+
+// host returns the state for host, creating it at the current ceiling.
+// The caller must hold s.mu.
+func (s *hostScheduler) host(host string) *hostState {
+	host = strings.ToLower(host)
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{
+			limiter: rate.NewLimiter(s.base, 1),
+			ceiling: s.base,
+			stats:   models.HostStats{Host: host},
+		}
+		s.hosts[host] = h
+	}
+	return h
+}
+
+// setBase changes the rate every host is allowed and recovers to
+func (s *hostScheduler) setBase(base rate.Limit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.base = base
+	for _, h := range s.hosts {
+		h.ceiling = base
+		if h.limiter.Limit() > base || h.failures == 0 {
+			h.limiter.SetLimit(base)
+		}
+	}
+}
+
+// setCrawlDelay caps a host's rate to one request per delay, as asked for by
+// its robots.txt
+func (s *hostScheduler) setCrawlDelay(host string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.host(host)
+	if limit := rate.Every(delay); limit < h.ceiling {
+		h.ceiling = limit
+		if h.limiter.Limit() > limit {
+			h.limiter.SetLimit(limit)
+		}
+	}
+}
+
+// wait blocks until host may be sent another request
+func (s *hostScheduler) wait(ctx context.Context, host string) error {
+	s.mu.Lock()
+	h := s.host(host)
+	pause := time.Until(h.pausedUntil)
+	h.stats.Requests++
+	s.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return h.limiter.Wait(ctx)
+}
+
+// observe records the outcome of a request to host and reports whether it
+// was the server pushing back, in which case the request may be retried
+func (s *hostScheduler) observe(host string, resp *http.Response, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.host(host)
+
+	switch {
+	case err != nil && isTimeout(err):
+		s.backoff(h, -1)
+		return true
+	case err != nil:
+		h.stats.Errors++
+		return false
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		h.stats.Throttled++
+		s.backoff(h, retryAfter(resp.Header.Get("Retry-After")))
+		return true
+	case resp.StatusCode >= 500:
+		s.backoff(h, -1)
+		return true
+	}
+
+	h.failures = 0
+	h.healthy++
+	if limit := h.limiter.Limit(); limit < h.ceiling && h.healthy >= recoverAfter {
+		h.healthy = 0
+		h.limiter.SetLimit(min(limit*1.5, h.ceiling))
+	}
+	return false
+}
+
+// retried counts a retry against host
+func (s *hostScheduler) retried(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.host(host).stats.Retries++
+}
+
+// backoff halves h's rate and pauses it, for wait if the server named a
+// delay (wait >= 0) and otherwise exponentially with jitter. The caller must
+// hold s.mu.
+func (s *hostScheduler) backoff(h *hostState, wait time.Duration) {
+	h.failures++
+	h.healthy = 0
+	h.stats.Backoffs++
+
+	limit := h.limiter.Limit()
+	if limit == rate.Inf {
+		limit = rate.Limit(1 / initialBackoff.Seconds())
+	}
+	h.limiter.SetLimit(max(limit/2, minHostRate))
+
+	if wait < 0 {
+		wait = initialBackoff << min(h.failures-1, 6)
+		wait = min(wait, maxBackoff)
+		wait += rand.N(wait/2 + 1)
+	}
+	if until := time.Now().Add(wait); until.After(h.pausedUntil) {
+		h.pausedUntil = until
+	}
+}
+
+// stats reports every host's counters and current rate, sorted by host
+func (s *hostScheduler) stats() []models.HostStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]models.HostStats, 0, len(s.hosts))
+	for _, h := range s.hosts {
+		st := h.stats
+		if limit := h.limiter.Limit(); limit != rate.Inf {
+			st.Rate = float64(limit)
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
+
+// resetStats clears the counters before a new crawl; rates are kept
+func (s *hostScheduler) resetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for host, h := range s.hosts {
+		h.stats = models.HostStats{Host: host}
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date. It returns -1 when the header is missing or invalid.
+func retryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	var wait time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	} else {
+		return -1
+	}
+	return min(max(wait, 0), maxRetryAfter)
+}
+
+// isTimeout reports whether err is a request timeout rather than a refusal
+// or a cancelled crawl
+func isTimeout(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -2,44 +2,202 @@ package crawler
 
 import (
 	"context"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/config"
 	"github.com/amosWeiskopf/crawlsmith/internal/models"
+	"github.com/amosWeiskopf/crawlsmith/pkg/utils"
 )
 
 // Crawler defines the interface for web crawling operations
 type Crawler interface {
 	// Crawl starts the crawling process
 	Crawl() (*models.CrawlResult, error)
-	
+
 	// CrawlWithContext starts crawling with a context for cancellation
 	CrawlWithContext(ctx context.Context) (*models.CrawlResult, error)
-	
+
 	// SetRateLimit sets the requests per second limit
 	SetRateLimit(requestsPerSecond int)
-	
+
 	// SetMaxDepth sets the maximum crawl depth
 	SetMaxDepth(depth int)
-	
+
 	// SetUserAgent sets the user agent string
 	SetUserAgent(userAgent string)
-	
+
 	// AddExcludePattern adds a URL pattern to exclude from crawling
 	AddExcludePattern(pattern string)
-	
+
 	// EnableJavaScript enables JavaScript rendering (requires headless browser)
 	EnableJavaScript(enabled bool)
 }
 
 // Options contains configuration for the crawler
 type Options struct {
-	MaxPerPath       int      // Maximum pages per path pattern
-	MaxPathTypes     int      // Maximum number of path types
-	MaxDepth         int      // Maximum crawl depth
-	RequestsPerSec   int      // Rate limit
-	UserAgent        string   // User agent string
-	FollowRobotsTxt  bool     // Respect robots.txt
-	ExtractContacts  bool     // Extract contact information
-	EnableJS         bool     // Enable JavaScript rendering
-	Timeout          int      // Request timeout in seconds
-	ExcludePatterns  []string // URL patterns to exclude
-	IncludeSubdomains bool    // Include subdomains in crawl
-}
\ No newline at end of file
+	MaxPerPath        int      // Maximum pages per path pattern
+	MaxPathTypes      int      // Maximum number of path types
+	MaxDepth          int      // Maximum crawl depth
+	MaxPages          int      // Maximum pages fetched per crawl
+	MaxWorkers        int      // Number of concurrent fetch workers
+	RequestsPerSec    int      // Rate limit
+	UserAgent         string   // User agent string
+	FollowRobotsTxt   bool     // Respect robots.txt
+	ExtractContacts   bool     // Extract contact information
+	PhoneRegion       string   // Region such as US or DE for national phone numbers; empty uses the page's country-code domain, else US
+	EnableJS          bool     // Enable JavaScript rendering
+	Timeout           int      // Request timeout in seconds
+	ExcludePatterns   []string // URL patterns to exclude
+	IncludeSubdomains bool     // Include subdomains in crawl
+	UseSitemaps       bool     // Discover XML sitemaps and crawl the URLs they list
+	CheckOutlinks     bool     // In list crawls, check the status of every link on the listed pages
+
+	StoragePath        string // Directory for resumable crawl state; empty keeps the crawl in memory
+	CheckpointInterval int    // Journal records written between syncs to disk
+	Incremental        bool   // Revalidate pages against the last completed crawl of the same host
+	RendererURL        string // DevTools endpoint of a headless browser, used when EnableJS is set
+	MaxRetries         int    // Retries per URL after a 429, 5xx or timeout
+
+	SubdomainWordlist []string // Labels probed as <label>.<domain> during subdomain discovery
+	DiscardPages      bool     // Leave pages out of the result; they still reach OnPage and Stream
+
+	WARCPath    string // Directory for WARC archives of every request and response; empty disables archiving
+	WARCMaxSize int64  // Bytes after which a new WARC file is started; zero or less never rotates
+
+	Canonical utils.CanonicalOptions // How URLs are canonicalized before deduplication
+	Traps     TrapOptions            // Limits past which discovered URLs are quarantined as crawler traps
+	Network   NetworkOptions         // Proxies and the addresses the crawler may connect to
+
+	// Auth is left out of the saved crawl manifest so secrets never reach disk
+	Auth AuthOptions `json:"-"`
+}
+
+// DefaultOptions returns the options used when no configuration is given.
+// The values mirror the crawler defaults in internal/config.
+func DefaultOptions() Options {
+	return Options{
+		MaxPerPath:      50,
+		MaxPathTypes:    100,
+		MaxDepth:        10,
+		MaxPages:        1000,
+		MaxWorkers:      10,
+		RequestsPerSec:  10,
+		UserAgent:       "CrawlSmith/1.0",
+		FollowRobotsTxt: true,
+		ExtractContacts: true,
+		Timeout:         30,
+		UseSitemaps:     true,
+
+		CheckpointInterval: 100,
+		MaxRetries:         3,
+
+		Traps: TrapOptions{
+			MaxPathDepth:      15,
+			MaxSegmentRepeats: 2,
+			MaxQueryParams:    8,
+			MaxCounterSteps:   50,
+			MaxNearDuplicates: 20,
+		},
+		Network: NetworkOptions{ProxyFailures: 3},
+	}
+}
+
+// OptionsFromConfig builds crawler options from the application configuration
+func OptionsFromConfig(cfg *config.Config) Options {
+	opts := DefaultOptions()
+	opts.MaxDepth = cfg.Crawler.MaxDepth
+	opts.MaxPages = cfg.Crawler.MaxPagesPerDomain
+	opts.MaxWorkers = cfg.Crawler.MaxWorkers
+	opts.RequestsPerSec = cfg.Crawler.RequestsPerSecond
+	opts.UserAgent = cfg.Crawler.UserAgent
+	opts.FollowRobotsTxt = cfg.Crawler.FollowRobotsTxt
+	opts.ExtractContacts = cfg.Crawler.ExtractContacts
+	opts.PhoneRegion = cfg.Crawler.PhoneRegion
+	opts.EnableJS = cfg.Crawler.EnableJavaScript
+	opts.RendererURL = cfg.Crawler.RendererURL
+	opts.MaxRetries = cfg.Crawler.MaxRetries
+	opts.SubdomainWordlist = cfg.Crawler.SubdomainWordlist
+	opts.UseSitemaps = cfg.Crawler.UseSitemaps
+	opts.Incremental = cfg.Crawler.Incremental
+	opts.Timeout = int(cfg.Crawler.Timeout.Seconds())
+
+	opts.Canonical = canonicalOptions(cfg.Crawler.Canonical)
+	opts.Auth = authOptions(cfg.Crawler.Auth)
+	opts.Traps = TrapOptions{
+		MaxPathDepth:      cfg.Crawler.Traps.MaxPathDepth,
+		MaxSegmentRepeats: cfg.Crawler.Traps.MaxSegmentRepeats,
+		MaxQueryParams:    cfg.Crawler.Traps.MaxQueryParams,
+		MaxCounterSteps:   cfg.Crawler.Traps.MaxCounterSteps,
+		MaxNearDuplicates: cfg.Crawler.Traps.MaxNearDuplicates,
+	}
+	opts.Network = NetworkOptions{
+		Proxies:       cfg.Crawler.Network.Proxies,
+		ProxyFailures: cfg.Crawler.Network.ProxyFailures,
+		BlockPrivate:  cfg.Crawler.Network.BlockPrivate,
+		AllowCIDRs:    cfg.Crawler.Network.AllowCIDRs,
+		DenyCIDRs:     cfg.Crawler.Network.DenyCIDRs,
+	}
+	opts.WARCPath = cfg.Storage.WARCPath
+	opts.WARCMaxSize = int64(cfg.Storage.WARCMaxSizeMB) << 20
+
+	if cfg.Storage.Type == "file" {
+		opts.StoragePath = cfg.Storage.Path
+		opts.CheckpointInterval = cfg.Storage.BatchSize
+	}
+	return opts
+}
+
+// canonicalOptions converts the canonicalization configuration
+func canonicalOptions(cfg config.CanonicalConfig) utils.CanonicalOptions {
+	opts := utils.CanonicalOptions{
+		StripTrailingSlash: cfg.StripTrailingSlash,
+		KeepIndexFiles:     cfg.KeepIndexFiles,
+		KeepTrackingParams: cfg.KeepTrackingParams,
+		Params:             utils.ParamRules{Allow: cfg.AllowParams, Deny: cfg.DenyParams},
+	}
+	for _, site := range cfg.Sites {
+		if opts.Sites == nil {
+			opts.Sites = make(map[string]utils.ParamRules)
+		}
+		opts.Sites[site.Host] = utils.ParamRules{Allow: site.AllowParams, Deny: site.DenyParams}
+	}
+	return opts
+}
+
+// authOptions converts the crawl credentials configuration
+func authOptions(cfg config.AuthConfig) AuthOptions {
+	opts := AuthOptions{CookieFile: cfg.CookieFile}
+	if cfg.Username != "" || cfg.Password != "" || cfg.Token != "" || len(cfg.Headers) > 0 {
+		opts.Hosts = append(opts.Hosts, HostAuth{
+			Headers:  cfg.Headers,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			Token:    cfg.Token,
+		})
+	}
+	for _, h := range cfg.Hosts {
+		opts.Hosts = append(opts.Hosts, HostAuth{
+			Host:     h.Host,
+			Headers:  h.Headers,
+			Username: h.Username,
+			Password: h.Password,
+			Token:    h.Token,
+		})
+	}
+	if l := cfg.Login; l.URL != "" {
+		opts.Login = &FormLogin{
+			URL:           l.URL,
+			UsernameField: l.UsernameField,
+			PasswordField: l.PasswordField,
+			Username:      l.Username,
+			Password:      l.Password,
+			SuccessText:   l.SuccessText,
+		}
+		for _, field := range l.Fields {
+			if opts.Login.Fields == nil {
+				opts.Login.Fields = make(map[string]string)
+			}
+			opts.Login.Fields[field.Name] = field.Value
+		}
+	}
+	return opts
+}
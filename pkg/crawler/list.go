@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// CrawlURLs fetches exactly the given URLs, with the usual extraction, and
+// follows none of their links. It suits audits of a fixed list such as a
+// migration mapping or landing pages. With Options.CheckOutlinks set, every
+// web link on the listed pages is requested once to record its status in
+// CrawlResult.LinkChecks.
+//
+// Scope, depth, exclude patterns and page limits do not apply to the list;
+// robots.txt of each listed host is still honoured when FollowRobotsTxt is
+// set. List crawls are not journaled, so they cannot be resumed and are never
+// incremental.
+func (c *WebCrawler) CrawlURLs(ctx context.Context, urls []string) (*models.CrawlResult, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URLs to crawl")
+	}
+	list := make([]*url.URL, 0, len(urls))
+	for _, raw := range urls {
+		u, err := parseStartURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return c.crawl(ctx, false, nil, list)
+}
+
+// startList turns run into a crawl of urls only. Nothing is journaled,
+// compared with an earlier crawl or discovered beyond the list.
+func (r *crawlRun) startList(urls []*url.URL) {
+	r.listed = urls
+	r.checks = make(map[string]*models.LinkCheck)
+	r.opts.StoragePath = ""
+	r.opts.Incremental = false
+	r.opts.UseSitemaps = false
+}
+
+// admitList schedules every listed URL once, skipping those robots.txt
+// disallows
+func (r *crawlRun) admitList() []task {
+	var queue []task
+	for _, u := range r.listed {
+		key := r.canon.URL(u)
+		r.collapse(u, key)
+		if r.seen[key] {
+			continue
+		}
+		r.seen[key] = true
+		r.linked[key] = true
+
+		if robots := r.robotsFor(u); robots != nil && !robots.allowed(u) {
+			r.blocked[key] = models.BlockedURL{URL: key, Source: models.BlockedByRobotsTxt, Rule: robots.rule(u)}
+			r.skip(key, 0, models.BlockedByRobotsTxt)
+			continue
+		}
+		r.scheduled++
+		queue = append(queue, task{url: key})
+	}
+	return queue
+}
+
+// checkLinks schedules a status check of every web link on a listed page
+// that is not itself listed, once per target
+func (r *crawlRun) checkLinks(page *models.Page) []task {
+	if !r.opts.CheckOutlinks {
+		return nil
+	}
+
+	var next []task
+	for _, link := range page.Links {
+		u, err := url.Parse(link.ToURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		key := r.canon.URL(u)
+		if r.seen[key] {
+			continue
+		}
+		check, ok := r.checks[key]
+		if !ok {
+			check = &models.LinkCheck{URL: key}
+			r.checks[key] = check
+			next = append(next, task{url: key, depth: 1, check: true})
+		}
+		if !slices.Contains(check.FoundOn, page.URL) {
+			check.FoundOn = append(check.FoundOn, page.URL)
+		}
+	}
+	return next
+}
+
+// recordCheck stores the outcome of a link check
+func (r *crawlRun) recordCheck(res fetchResult) {
+	check := r.checks[res.task.url]
+	if res.err != nil {
+		check.Error = res.err.Error()
+		return
+	}
+	check.StatusCode = res.page.StatusCode
+	check.FinalURL = res.page.FinalURL
+}
+
+// linkChecks lists the checked links sorted by URL
+func (r *crawlRun) linkChecks() []models.LinkCheck {
+	if len(r.checks) == 0 {
+		return nil
+	}
+	checks := make([]models.LinkCheck, 0, len(r.checks))
+	for _, check := range r.checks {
+		checks = append(checks, *check)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].URL < checks[j].URL
+	})
+	return checks
+}
+
+// checkLink requests a link target without downloading it, using HEAD and
+// falling back to GET for servers that do not support HEAD
+func (c *WebCrawler) checkLink(ctx context.Context, run *crawlRun, t task) fetchResult {
+	status, finalURL, err := c.linkStatus(ctx, http.MethodHead, t.url, run.opts)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, finalURL, err = c.linkStatus(ctx, http.MethodGet, t.url, run.opts)
+	}
+	if err != nil {
+		return fetchResult{task: t, err: fmt.Errorf("checking %s: %w", t.url, err)}
+	}
+	return fetchResult{task: t, page: &models.Page{URL: t.url, StatusCode: status, FinalURL: finalURL}}
+}
+
+// linkStatus sends one request, retrying like fetch while the server pushes
+// back, and returns the final status and the URL it ended on when a redirect
+// was followed
+func (c *WebCrawler) linkStatus(ctx context.Context, method, rawURL string, opts Options) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("User-Agent", opts.UserAgent)
+	c.authorize(req, opts.Auth)
+
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		if err := c.hosts.wait(ctx, req.URL.Host); err != nil {
+			return 0, "", err
+		}
+		resp, err = c.client.Do(req.Clone(ctx))
+		if !c.hosts.observe(req.URL.Host, resp, err) || attempt >= opts.MaxRetries || ctx.Err() != nil {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		c.hosts.retried(req.URL.Host)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	// The body is never read; only the status matters
+	resp.Body.Close()
+
+	var finalURL string
+	if u := resp.Request.URL.String(); u != rawURL {
+		finalURL = u
+	}
+	return resp.StatusCode, finalURL, nil
+}
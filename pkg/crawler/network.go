@@ -0,0 +1,409 @@
+package crawler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// NetworkOptions controls which proxies the crawler's requests leave through
+// and which addresses it may connect to
+type NetworkOptions struct {
+	// Proxies are http, https or socks5 URLs. Each host sticks to the proxy
+	// it was first given; new hosts are handed out in rotation. Proxy URLs
+	// may hold credentials, so they are not saved with a resumable crawl.
+	Proxies       []string `json:"-"`
+	ProxyFailures int      // Consecutive failed requests after which a proxy is dropped; zero keeps it
+
+	BlockPrivate bool     // Refuse loopback, private, link-local and other internal addresses
+	AllowCIDRs   []string // Ranges or addresses reachable despite BlockPrivate
+	DenyCIDRs    []string // Ranges or addresses never connected to
+}
+
+const (
+	// dialTimeout bounds each connection attempt
+	dialTimeout = 30 * time.Second
+	// proxyCheckTimeout bounds the health check of each proxy
+	proxyCheckTimeout = 5 * time.Second
+)
+
+// errBlockedAddress is returned for requests the address rules refuse
+var errBlockedAddress = errors.New("address blocked by network rules")
+
+// errNoProxy is returned once every proxy of the pool has been dropped
+var errNoProxy = errors.New("no working proxy left")
+
+// internalRanges are reserved for internal use besides the loopback,
+// private, link-local and multicast ranges net/netip knows about
+var internalRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+}
+
+// SetNetwork replaces the proxies and address rules used by later crawls.
+// Resume does not restore proxies, so set them again on a resumed crawler.
+func (c *WebCrawler) SetNetwork(network NetworkOptions) error {
+	guard, err := newAddressGuard(network)
+	if err != nil {
+		return err
+	}
+	proxies, err := newProxyPool(network.Proxies, network.ProxyFailures)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.opts.Network = network
+	c.guard = guard
+	c.proxies = proxies
+	c.setTransport()
+	return nil
+}
+
+// setTransport rebuilds the HTTP transport from the resolver, proxies and
+// address rules. The caller holds c.mu.
+func (c *WebCrawler) setTransport() {
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second, Resolver: c.resolver}
+	guard := c.guard
+	c.dial = guard.dialContext(dialer, c.proxies.addrs())
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = c.dial
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		var proxyURL *url.URL
+		var err error
+		if p, ok := req.Context().Value(proxyKey{}).(*proxy); ok {
+			proxyURL = p.url
+		} else {
+			proxyURL, err = http.ProxyFromEnvironment(req)
+		}
+		if err != nil || proxyURL == nil {
+			return nil, err
+		}
+		// The proxy connects to the site, so the site's addresses are
+		// checked here rather than when dialing
+		if err := guard.checkHost(req.Context(), dialer.Resolver, req.URL.Hostname()); err != nil {
+			return nil, err
+		}
+		return proxyURL, nil
+	}
+
+	c.client.Transport = transport
+	if c.proxies != nil {
+		c.client.Transport = &proxyTransport{base: transport, pool: c.proxies}
+	}
+}
+
+// addressGuard decides which IP addresses the crawler may connect to
+type addressGuard struct {
+	blockPrivate bool
+	allow, deny  []netip.Prefix
+}
+
+// newAddressGuard parses the address rules of opts
+func newAddressGuard(opts NetworkOptions) (*addressGuard, error) {
+	allow, err := parsePrefixes(opts.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parsePrefixes(opts.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	return &addressGuard{blockPrivate: opts.BlockPrivate, allow: allow, deny: deny}, nil
+}
+
+// parsePrefixes parses CIDR ranges and single addresses
+func parsePrefixes(ranges []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(ranges))
+	for _, r := range ranges {
+		if p, err := netip.ParsePrefix(r); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q", r)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// active reports whether the guard refuses any address
+func (g *addressGuard) active() bool {
+	return g != nil && (g.blockPrivate || len(g.deny) > 0)
+}
+
+// allowed reports whether the crawler may connect to addr. The most specific
+// matching allow or deny range decides; without one, internal addresses are
+// refused when BlockPrivate is set.
+func (g *addressGuard) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	allowBits, denyBits := longestMatch(g.allow, addr), longestMatch(g.deny, addr)
+	switch {
+	case denyBits >= 0 && denyBits >= allowBits:
+		return false
+	case allowBits >= 0:
+		return true
+	}
+	return !g.blockPrivate || !isInternal(addr)
+}
+
+// longestMatch returns the length of the longest prefix containing addr, or -1
+func longestMatch(prefixes []netip.Prefix, addr netip.Addr) int {
+	bits := -1
+	for _, p := range prefixes {
+		if p.Contains(addr) && p.Bits() > bits {
+			bits = p.Bits()
+		}
+	}
+	return bits
+}
+
+// isInternal reports whether addr belongs to a loopback, private, link-local
+// or otherwise internal network
+func isInternal(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() ||
+		addr.IsMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsLinkLocalMulticast() {
+		return true
+	}
+	for _, p := range internalRanges {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup resolves host, which may be an IP literal
+func lookup(ctx context.Context, resolver *net.Resolver, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return resolver.LookupNetIP(ctx, "ip", host)
+}
+
+// checkHost returns an error unless every address of host is allowed
+func (g *addressGuard) checkHost(ctx context.Context, resolver *net.Resolver, host string) error {
+	if !g.active() {
+		return nil
+	}
+	addrs, err := lookup(ctx, resolver, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.allowed(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", errBlockedAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// dialContext returns a dial function that only connects to allowed
+// addresses. Hosts are resolved once and their allowed addresses dialed
+// directly, so a second DNS answer cannot slip past the check. The exempt
+// addresses, those of configured proxies, are dialed as they are.
+func (g *addressGuard) dialContext(d *net.Dialer, exempt map[string]bool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if !g.active() || exempt[addr] {
+			return d.DialContext(ctx, network, addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := lookup(ctx, d.Resolver, host)
+		if err != nil {
+			return nil, err
+		}
+
+		err = fmt.Errorf("%w: %s", errBlockedAddress, host)
+		for _, ip := range addrs {
+			if !g.allowed(ip) {
+				continue
+			}
+			var conn net.Conn
+			if conn, err = d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// proxyKey is the context key under which proxyTransport passes the chosen
+// proxy to the transport's Proxy function
+type proxyKey struct{}
+
+// proxy is one member of a proxyPool
+type proxy struct {
+	url      *url.URL
+	addr     string // host:port dialed to reach the proxy
+	failures int
+	down     bool
+}
+
+// proxyPool hands out proxies in rotation, keeping each host on one proxy
+// until that proxy is dropped
+type proxyPool struct {
+	mu          sync.Mutex
+	proxies     []*proxy
+	next        int
+	hosts       map[string]*proxy
+	maxFailures int
+}
+
+// newProxyPool parses the proxy URLs. It returns nil when there are none.
+func newProxyPool(rawURLs []string, maxFailures int) (*proxyPool, error) {
+	if len(rawURLs) == 0 {
+		return nil, nil
+	}
+	pool := &proxyPool{hosts: make(map[string]*proxy), maxFailures: maxFailures}
+	for i, raw := range rawURLs {
+		// Errors name proxies by position, since their URLs may hold passwords
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %d: %w", i+1, errors.Unwrap(err))
+		}
+		port := u.Port()
+		switch u.Scheme {
+		case "http":
+			port = cmp.Or(port, "80")
+		case "https":
+			port = cmp.Or(port, "443")
+		case "socks5", "socks5h":
+			port = cmp.Or(port, "1080")
+		default:
+			return nil, fmt.Errorf("invalid proxy %d: scheme must be http, https or socks5", i+1)
+		}
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("invalid proxy %d: missing host", i+1)
+		}
+		pool.proxies = append(pool.proxies, &proxy{url: u, addr: net.JoinHostPort(u.Hostname(), port)})
+	}
+	return pool, nil
+}
+
+// addrs returns the addresses of the pool's proxies
+func (p *proxyPool) addrs() map[string]bool {
+	if p == nil {
+		return nil
+	}
+	addrs := make(map[string]bool, len(p.proxies))
+	for _, pr := range p.proxies {
+		addrs[pr.addr] = true
+	}
+	return addrs
+}
+
+// pick returns the proxy for host
+func (p *proxyPool) pick(host string) (*proxy, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pr := p.hosts[host]; pr != nil && !pr.down {
+		return pr, nil
+	}
+	for range p.proxies {
+		pr := p.proxies[p.next]
+		p.next = (p.next + 1) % len(p.proxies)
+		if !pr.down {
+			p.hosts[host] = pr
+			return pr, nil
+		}
+	}
+	return nil, errNoProxy
+}
+
+// observe records the outcome of a request through pr and reports whether
+// it got pr dropped. Requests refused by the address rules or cancelled by
+// the crawler say nothing about the proxy.
+func (p *proxyPool) observe(pr *proxy, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case err == nil:
+		pr.failures = 0
+	case errors.Is(err, errBlockedAddress), errors.Is(err, context.Canceled):
+	case !pr.down:
+		pr.failures++
+		if p.maxFailures > 0 && pr.failures >= p.maxFailures {
+			pr.down = true
+			return true
+		}
+	}
+	return false
+}
+
+// check restores every proxy, then drops those that do not accept a
+// connection. It returns how many are left.
+func (p *proxyPool) check(ctx context.Context, dial func(ctx context.Context, network, addr string) (net.Conn, error)) int {
+	down := make([]bool, len(p.proxies))
+	var wg sync.WaitGroup
+	for i, pr := range p.proxies {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, proxyCheckTimeout)
+			defer cancel()
+			conn, err := dial(ctx, "tcp", addr)
+			if err != nil {
+				down[i] = true
+				return
+			}
+			conn.Close()
+		}(i, pr.addr)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hosts = make(map[string]*proxy)
+	healthy := 0
+	for i, pr := range p.proxies {
+		pr.failures, pr.down = 0, down[i]
+		if !pr.down {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// proxyTransport sends each request through the pool's proxy for its host
+// and reports the outcome back to the pool
+type proxyTransport struct {
+	base *http.Transport
+	pool *proxyPool
+}
+
+// RoundTrip implements http.RoundTripper. A request whose failure got its
+// proxy dropped is sent again through the next one, unless it has a body.
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		pr, err := t.pool.pick(req.URL.Hostname())
+		if err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req.WithContext(context.WithValue(req.Context(), proxyKey{}, pr)))
+		if !t.pool.observe(pr, err) || (req.Body != nil && req.Body != http.NoBody) {
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	hashSegment    = regexp.MustCompile(`^[0-9a-f]{16,}$`)
+	slugSegment    = regexp.MustCompile(`^[a-z0-9]+(?:[-_+][a-z0-9]+)+$`)
+	codeSegment    = regexp.MustCompile(`^[a-z]*\d{3,}[a-z0-9]*$`)
+)
+
+// PathPattern reduces a URL path to its template, replacing variable
+// segments with placeholders: /blog/2024/some-slug becomes
+// /blog/{year}/{slug} and /product/12345 becomes /product/{id}. The first
+// segment is never a slug, so sections such as /about-us keep their name.
+func PathPattern(u *url.URL) string {
+	p := u.Path
+	if p == "" || p == "/" {
+		return "/"
+	}
+
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = segmentPattern(segment, i == 0)
+	}
+
+	pattern := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(p, "/") {
+		pattern += "/"
+	}
+	return pattern
+}
+
+// segmentPattern classifies a single path segment
+func segmentPattern(segment string, first bool) string {
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+	segment = strings.ToLower(segment)
+
+	// Keep file extensions so /docs/{slug}.pdf and /docs/{slug}.html stay apart
+	ext := path.Ext(segment)
+	if ext != "" && len(ext) <= 5 && !numericSegment.MatchString(ext[1:]) {
+		segment = strings.TrimSuffix(segment, ext)
+	} else {
+		ext = ""
+	}
+
+	switch {
+	case segment == "":
+		return ext
+	case numericSegment.MatchString(segment):
+		if n, _ := strconv.Atoi(segment); len(segment) == 4 && n >= 1900 && n <= 2099 {
+			return "{year}" + ext
+		}
+		return "{id}" + ext
+	case uuidSegment.MatchString(segment):
+		return "{uuid}" + ext
+	case hashSegment.MatchString(segment):
+		return "{hash}" + ext
+	case slugSegment.MatchString(segment) && !first:
+		return "{slug}" + ext
+	case codeSegment.MatchString(segment):
+		return "{id}" + ext
+	}
+	return segment + ext
+}
+
+// pathBudget caps how many URLs are fetched per path pattern and how many
+// distinct patterns a crawl may explore. Patterns of different hosts are
+// budgeted apart.
+type pathBudget struct {
+	maxPerPath   int
+	maxPathTypes int
+	order        []budgetKey
+	crawled      map[budgetKey]int
+	skipped      map[budgetKey]int
+}
+
+// budgetKey identifies a path pattern on one host
+type budgetKey struct {
+	host, pattern string
+}
+
+// newPathBudget creates a budget; zero or negative limits are unlimited
+func newPathBudget(maxPerPath, maxPathTypes int) *pathBudget {
+	return &pathBudget{
+		maxPerPath:   maxPerPath,
+		maxPathTypes: maxPathTypes,
+		crawled:      make(map[budgetKey]int),
+		skipped:      make(map[budgetKey]int),
+	}
+}
+
+// allow reports whether another URL with the given pattern on host may be
+// fetched and records the decision
+func (b *pathBudget) allow(host, pattern string) bool {
+	key := budgetKey{host: strings.ToLower(host), pattern: pattern}
+	count, known := b.crawled[key]
+	if !known {
+		if _, wasSkipped := b.skipped[key]; !wasSkipped {
+			b.order = append(b.order, key)
+		}
+	}
+
+	switch {
+	case !known && b.maxPathTypes > 0 && len(b.crawled) >= b.maxPathTypes:
+		b.skipped[key]++
+		return false
+	case b.maxPerPath > 0 && count >= b.maxPerPath:
+		b.skipped[key]++
+		return false
+	}
+
+	b.crawled[key] = count + 1
+	return true
+}
+
+// truncated lists every pattern that had URLs skipped, busiest first
+func (b *pathBudget) truncated() []models.PathPattern {
+	var patterns []models.PathPattern
+	for _, key := range b.order {
+		if skipped := b.skipped[key]; skipped > 0 {
+			patterns = append(patterns, models.PathPattern{
+				Host:    key.host,
+				Pattern: key.pattern,
+				Crawled: b.crawled[key],
+				Skipped: skipped,
+			})
+		}
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].Skipped > patterns[j].Skipped
+	})
+	return patterns
+}
@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathPattern(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/", "/"},
+		{"https://example.com", "/"},
+		{"https://example.com/blog/2024/some-slug", "/blog/{year}/{slug}"},
+		{"https://example.com/product/12345", "/product/{id}"},
+		{"https://example.com/product/12345/", "/product/{id}/"},
+		{"https://example.com/about", "/about"},
+		{"https://example.com/about-us", "/about-us"},
+		{"https://example.com/about-us/our-team", "/about-us/{slug}"},
+		{"https://example.com/page1", "/page1"},
+		{"https://example.com/item/sku12345", "/item/{id}"},
+		{"https://example.com/u/3f2b8c1e-9a4d-4c2b-8e7f-1a2b3c4d5e6f", "/u/{uuid}"},
+		{"https://example.com/docs/annual-report.pdf", "/docs/{slug}.pdf"},
+		{"https://example.com/Blog/My-Post?page=2", "/blog/{slug}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, PathPattern(u))
+		})
+	}
+}
+
+func TestPathBudget(t *testing.T) {
+	b := newPathBudget(2, 2)
+
+	assert.True(t, b.allow("example.com", "/product/{id}"))
+	assert.True(t, b.allow("example.com", "/product/{id}"))
+	assert.False(t, b.allow("example.com", "/product/{id}"))
+	assert.True(t, b.allow("shop.example.com", "/product/{id}"), "each host has its own budget")
+	assert.False(t, b.allow("example.com", "/blog/{slug}"), "third pattern exceeds MaxPathTypes")
+
+	truncated := b.truncated()
+	require.Len(t, truncated, 2)
+	assert.Equal(t, "example.com", truncated[0].Host)
+	assert.Equal(t, "/product/{id}", truncated[0].Pattern)
+	assert.Equal(t, 2, truncated[0].Crawled)
+	assert.Equal(t, 1, truncated[0].Skipped)
+	assert.Equal(t, "/blog/{slug}", truncated[1].Pattern)
+	assert.Equal(t, 0, truncated[1].Crawled)
+}
+
+func TestCrawlTruncatesPathPatterns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`<html><body>
+				<a href="/product/1">1</a><a href="/product/2">2</a>
+				<a href="/product/3">3</a><a href="/product/4">4</a>
+			</body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body>` + r.URL.Path + `</body></html>`))
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, 2, 10)
+	require.NoError(t, err)
+
+	result, err := c.Crawl()
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.TotalPages)
+	require.Len(t, result.TruncatedPatterns, 1)
+	assert.Equal(t, "/product/{id}", result.TruncatedPatterns[0].Pattern)
+	assert.Equal(t, 2, result.TruncatedPatterns[0].Skipped)
+}
@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// previousCrawl is the baseline an incremental crawl is compared against
+type previousCrawl struct {
+	id    string
+	pages map[string]*models.Page
+}
+
+// findPreviousCrawl returns the ID of the most recent completed crawl of host
+// under storagePath, other than exclude. It returns "" when there is none.
+func findPreviousCrawl(storagePath, host, exclude string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(storagePath, "crawls"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to list previous crawls: %w", err)
+	}
+
+	var latest *crawlManifest
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == exclude {
+			continue
+		}
+		m, err := readManifest(crawlDir(storagePath, entry.Name()))
+		if err != nil || m.Status != statusCompleted {
+			continue
+		}
+		u, err := url.Parse(m.StartURL)
+		if err != nil || !strings.EqualFold(u.Host, host) {
+			continue
+		}
+		if latest == nil || m.StartedAt.After(latest.StartedAt) {
+			latest = m
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+	return latest.ID, nil
+}
+
+// loadPreviousCrawl reads the pages fetched by a persisted crawl
+func loadPreviousCrawl(storagePath, crawlID string) (*previousCrawl, error) {
+	f, err := os.Open(filepath.Join(crawlDir(storagePath, crawlID), journalFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load previous crawl %s: %w", crawlID, err)
+	}
+	defer f.Close()
+
+	records, _, err := readJournal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := &previousCrawl{id: crawlID, pages: make(map[string]*models.Page, len(records))}
+	for _, rec := range records {
+		if rec.Page != nil && rec.Error == "" {
+			prev.pages[rec.URL] = rec.Page
+		}
+	}
+	return prev, nil
+}
+
+// setConditionalHeaders asks the server to answer 304 when prev is still current
+func setConditionalHeaders(req *http.Request, prev *models.Page) {
+	if prev == nil {
+		return
+	}
+	if prev.ETag != "" {
+		req.Header.Set("If-None-Match", prev.ETag)
+	}
+	if prev.LastModified != "" {
+		req.Header.Set("If-Modified-Since", prev.LastModified)
+	}
+}
+
+// notModified reuses the stored copy of a page the server reported unchanged
+func notModified(prev *models.Page, resp *http.Response) *models.Page {
+	page := *prev
+	page.CrawledAt = time.Now()
+	page.Change = models.ChangeUnchanged
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		page.ETag = etag
+	}
+	if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		page.LastModified = modified
+	}
+	return &page
+}
+
+// changeStatus compares a freshly fetched page with its previous version
+func changeStatus(prev, page *models.Page) string {
+	switch {
+	case prev == nil:
+		return models.ChangeNew
+	case page.StatusCode == http.StatusNotFound || page.StatusCode == http.StatusGone:
+		return models.ChangeRemoved
+	case page.StatusCode == prev.StatusCode && page.ContentHash != "" && page.ContentHash == prev.ContentHash:
+		return models.ChangeUnchanged
+	}
+	return models.ChangeChanged
+}
+
+// removedURLs lists previously crawled URLs that are gone: they now return
+// 404 or 410, or, when complete is set, they were not reached at all
+func (r *crawlRun) removedURLs(complete bool) []string {
+	removed := append([]string(nil), r.removed...)
+	if complete {
+		for u := range r.previous.pages {
+			if !r.fetched[u] {
+				removed = append(removed, u)
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// Renderer executes a page's JavaScript and returns the resulting document.
+// Implementations must be safe for concurrent use by the crawl workers.
+type Renderer interface {
+	// Render loads pageURL, whose response body transcoded to UTF-8 is body,
+	// and returns the DOM after scripts have run
+	Render(ctx context.Context, pageURL string, body []byte) (*Rendered, error)
+
+	// Close releases the renderer's resources
+	Close() error
+}
+
+// Rendered is the outcome of rendering one page
+type Rendered struct {
+	HTML          string
+	ConsoleErrors []string
+}
+
+// StaticRenderer is a Renderer that runs no JavaScript. It returns the raw
+// body, or the document in Pages for that URL, which makes it useful in tests.
+type StaticRenderer struct {
+	Pages map[string]string
+}
+
+// Render returns the configured document for pageURL or the raw body
+func (s StaticRenderer) Render(ctx context.Context, pageURL string, body []byte) (*Rendered, error) {
+	if html, ok := s.Pages[pageURL]; ok {
+		return &Rendered{HTML: html}, nil
+	}
+	return &Rendered{HTML: string(body)}, nil
+}
+
+// Close is a no-op
+func (s StaticRenderer) Close() error {
+	return nil
+}
+
+// SetRenderer sets the renderer used when JavaScript is enabled. Without one,
+// a DevTools renderer is connected to Options.RendererURL for each crawl.
+func (c *WebCrawler) SetRenderer(r Renderer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.renderer = r
+}
+
+// renderFetch sends the requests of rendered pages through the crawler's own
+// client, so they get its user agent, credentials, rate limits, proxies and
+// address rules
+func (c *WebCrawler) renderFetch(opts Options) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		req.Header.Set("User-Agent", opts.UserAgent)
+		c.authorize(req, opts.Auth)
+		if err := c.hosts.wait(req.Context(), req.URL.Host); err != nil {
+			return nil, err
+		}
+		resp, err := c.client.Do(req)
+		c.hosts.observe(req.URL.Host, resp, err)
+		return resp, err
+	}
+}
+
+// linkDiff returns the URLs that only appear in rendered or only in raw
+func linkDiff(raw, rendered []string) (renderedOnly, rawOnly []string) {
+	inRaw := make(map[string]bool, len(raw))
+	for _, u := range raw {
+		inRaw[u] = true
+	}
+	inRendered := make(map[string]bool, len(rendered))
+	for _, u := range rendered {
+		if !inRendered[u] && !inRaw[u] {
+			renderedOnly = append(renderedOnly, u)
+		}
+		inRendered[u] = true
+	}
+	for u := range inRaw {
+		if !inRendered[u] {
+			rawOnly = append(rawOnly, u)
+		}
+	}
+	sort.Strings(rawOnly)
+	return renderedOnly, rawOnly
+}
@@ -0,0 +1,215 @@
+package crawler
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// maxRedirects is how many hops are followed before a chain is cut off
+const maxRedirects = 10
+
+// checkRedirect stops following a chain that loops or grows too long and
+// hands back the last redirect response so the chain can still be recorded
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return http.ErrUseLastResponse
+	}
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return http.ErrUseLastResponse
+		}
+	}
+	return nil
+}
+
+// redirectChain rebuilds the hops that led to resp, oldest first
+func redirectChain(resp *http.Response) []models.RedirectHop {
+	var hops []models.RedirectHop
+	if isRedirect(resp.StatusCode) {
+		// The chain was cut short by checkRedirect
+		hops = append(hops, redirectHop(resp))
+	}
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		hops = append(hops, redirectHop(r))
+	}
+	for i, j := 0, len(hops)-1; i < j; i, j = i+1, j-1 {
+		hops[i], hops[j] = hops[j], hops[i]
+	}
+	return hops
+}
+
+// redirectHop describes one redirect response
+func redirectHop(resp *http.Response) models.RedirectHop {
+	hop := models.RedirectHop{
+		URL:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Location:   resp.Header.Get("Location"),
+	}
+	if loc, err := resp.Location(); err == nil {
+		hop.Location = loc.String()
+	}
+	return hop
+}
+
+// isRedirectLoop reports whether a chain comes back to a URL it visited
+func isRedirectLoop(hops []models.RedirectHop) bool {
+	visited := make(map[string]bool, len(hops))
+	for _, hop := range hops {
+		visited[hop.URL] = true
+	}
+	return len(hops) > 0 && visited[hops[len(hops)-1].Location]
+}
+
+// isRedirect reports whether status is a redirect the client follows
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// fetchTrace records connection timings through httptrace. Redirects run
+// several requests; DNS, connect and TLS time add up across them and TTFB
+// is measured to the first byte of the final response.
+type fetchTrace struct {
+	mu                            sync.Mutex
+	start                         time.Time
+	dnsStart, connStart, tlsStart time.Time
+	dns, connect, tls, firstByte  time.Duration
+}
+
+// newFetchTrace starts timing a fetch
+func newFetchTrace() *fetchTrace {
+	return &fetchTrace{start: time.Now()}
+}
+
+// clientTrace returns the hooks that feed t
+func (t *fetchTrace) clientTrace() *httptrace.ClientTrace {
+	// Dials may run on transport goroutines, so every hook takes the lock
+	mark := func(at *time.Time) {
+		t.mu.Lock()
+		*at = time.Now()
+		t.mu.Unlock()
+	}
+	add := func(total *time.Duration, since *time.Time) {
+		t.mu.Lock()
+		if !since.IsZero() {
+			*total += time.Since(*since)
+		}
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { add(&t.dns, &t.dnsStart) },
+		ConnectStart:      func(string, string) { mark(&t.connStart) },
+		ConnectDone:       func(string, string, error) { add(&t.connect, &t.connStart) },
+		TLSHandshakeStart: func() { mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { add(&t.tls, &t.tlsStart) },
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// timings reports the trace in milliseconds, with total taken now
+func (t *fetchTrace) timings() *models.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return &models.Timings{
+		DNS:     milliseconds(t.dns),
+		Connect: milliseconds(t.connect),
+		TLS:     milliseconds(t.tls),
+		TTFB:    milliseconds(t.firstByte),
+		Total:   milliseconds(time.Since(t.start)),
+	}
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// decodeBody undoes the Content-Encoding the server applied. The crawler asks
+// for compression itself so it can report it, which means the transport
+// leaves decoding to us.
+func decodeBody(encoding string, body io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return flate.NewReader(body), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// clickDepth returns the click depth of the page fetched for t: the depth of
+// the link that led to it, or -1 when no link has led to it so far, as for
+// sitemap-only URLs
+func (r *crawlRun) clickDepth(t task) int {
+	if !r.linked[t.url] {
+		return -1
+	}
+	return t.depth
+}
+
+// clickDepths checks the click depths of the collected pages once the crawl
+// is over, when every link is known: a page first reached through a sitemap
+// or a longer path may turn out to be fewer link hops from the seed.
+func (r *crawlRun) clickDepths() {
+	index := make(map[string]int, len(r.result.Pages))
+	for i := range r.result.Pages {
+		index[r.result.Pages[i].URL] = i
+		r.result.Pages[i].ClickDepth = -1
+	}
+
+	start, ok := index[r.canon.URL(r.seed)]
+	if !ok {
+		return
+	}
+	r.result.Pages[start].ClickDepth = 0
+	queue := []int{start}
+	for len(queue) > 0 {
+		page := &r.result.Pages[queue[0]]
+		queue = queue[1:]
+		for _, link := range page.Links {
+			u, err := url.Parse(link.ToURL)
+			if err != nil {
+				continue
+			}
+			i, ok := index[r.canon.URL(u)]
+			if ok && r.result.Pages[i].ClickDepth < 0 {
+				r.result.Pages[i].ClickDepth = page.ClickDepth + 1
+				queue = append(queue, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/temoto/robotstxt"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// robotsRules holds the parsed robots.txt of a host
+type robotsRules struct {
+	data  *robotstxt.RobotsData
+	agent string
+	rules []robotsRule
+}
+
+// robotsRule is one Allow/Disallow line of the group that applies to our
+// agent. robotstxt makes the decision; the lines are kept so a blocked URL
+// can be reported with the rule that blocked it.
+type robotsRule struct {
+	line    string
+	path    string
+	pattern *regexp.Regexp
+}
+
+// fetchRobots downloads and parses robots.txt for the host of origin. Any
+// failure to fetch or parse it is treated as "allow all", matching Google's
+// behaviour for missing files.
+func (c *WebCrawler) fetchRobots(ctx context.Context, opts Options, origin *url.URL) *robotsRules {
+	robotsURL := url.URL{Scheme: origin.Scheme, Host: origin.Host, Path: "/robots.txt"}
+	userAgent := opts.UserAgent
+
+	status, body, err := c.get(ctx, robotsURL.String(), opts, maxBodySize)
+	if err != nil {
+		return nil
+	}
+	data, err := robotstxt.FromStatusAndBytes(status, body)
+	if err != nil {
+		return nil
+	}
+
+	rules := &robotsRules{data: data, agent: userAgent}
+	if status >= 200 && status < 300 {
+		rules.rules = groupRules(body, userAgent)
+	}
+	return rules
+}
+
+// hostRobots fetches robots.txt for the host of u and applies its crawl delay
+func (c *WebCrawler) hostRobots(ctx context.Context, opts Options, u *url.URL) *robotsRules {
+	rules := c.fetchRobots(ctx, opts, u)
+	if delay := rules.crawlDelay(); delay > 0 {
+		c.hosts.setCrawlDelay(u.Host, delay)
+	}
+	return rules
+}
+
+// robotsFor returns the robots.txt rules of the host of u, fetching them the
+// first time the host is seen, or nil when robots.txt is not followed
+func (r *crawlRun) robotsFor(u *url.URL) *robotsRules {
+	if r.loadRobots == nil {
+		return nil
+	}
+	rules, ok := r.hostRobots[u.Host]
+	if !ok {
+		rules = r.loadRobots(u)
+		r.hostRobots[u.Host] = rules
+	}
+	return rules
+}
+
+// sitemaps returns the sitemap URLs declared with Sitemap: lines
+func (r *robotsRules) sitemaps() []string {
+	if r == nil || r.data == nil {
+		return nil
+	}
+	return r.data.Sitemaps
+}
+
+// crawlDelay returns the Crawl-delay of the group that applies to our agent
+func (r *robotsRules) crawlDelay() time.Duration {
+	if r == nil || r.data == nil {
+		return 0
+	}
+	return r.data.FindGroup(r.agent).CrawlDelay
+}
+
+// allowed reports whether robots.txt permits fetching u
+func (r *robotsRules) allowed(u *url.URL) bool {
+	if r == nil || r.data == nil {
+		return true
+	}
+	return r.data.TestAgent(robotsPath(u), r.agent)
+}
+
+// rule returns the robots.txt line that decides u, using the same
+// longest-match precedence as robotstxt
+func (r *robotsRules) rule(u *url.URL) string {
+	if r == nil {
+		return ""
+	}
+	path := robotsPath(u)
+
+	var best *robotsRule
+	for i := range r.rules {
+		rule := &r.rules[i]
+		var matched bool
+		if rule.pattern != nil {
+			matched = rule.pattern.MatchString(path)
+		} else {
+			matched = strings.HasPrefix(path, rule.path)
+		}
+		if matched && (best == nil || len(rule.path) > len(best.path)) {
+			best = rule
+		}
+	}
+
+	if best == nil {
+		// Everything is disallowed when robots.txt answered with a 5xx
+		return "Disallow: /"
+	}
+	return best.line
+}
+
+// robotsPath is the path and query that robots rules are matched against
+func robotsPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return path
+}
+
+// groupRules collects the Allow/Disallow lines of the group that applies to
+// userAgent: the longest User-agent token it starts with, or * otherwise
+func groupRules(body []byte, userAgent string) []robotsRule {
+	agent := strings.ToLower(userAgent)
+	groups := make(map[string][]robotsRule)
+
+	var current []string
+	inRules := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			current = append(current, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{line: strings.TrimSpace(line), path: value}
+			if strings.ContainsAny(value, "*$") {
+				rule.pattern = robotsPattern(value)
+			}
+			for _, a := range current {
+				groups[a] = append(groups[a], rule)
+			}
+		}
+	}
+
+	best := "*"
+	for a := range groups {
+		if a != "*" && strings.HasPrefix(agent, a) && (best == "*" || len(a) > len(best)) {
+			best = a
+		}
+	}
+	return groups[best]
+}
+
+// robotsPattern compiles a robots.txt path that uses * or $ wildcards
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(path, "$")), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// headerDirectives returns the X-Robots-Tag directives addressed to all
+// crawlers, to Googlebot or to userAgent
+func headerDirectives(header http.Header, userAgent string) []string {
+	agent := strings.ToLower(userAgent)
+	if i := strings.IndexAny(agent, "/ "); i > 0 {
+		agent = agent[:i]
+	}
+
+	var directives []string
+	for _, value := range header.Values("X-Robots-Tag") {
+		// A value may be scoped to one crawler, as in "googlebot: noindex"
+		if name, rest, ok := strings.Cut(value, ":"); ok && !strings.Contains(name, ",") && !isValuedDirective(name) {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "googlebot" && name != agent {
+				continue
+			}
+			value = rest
+		}
+		for _, d := range strings.Split(value, ",") {
+			if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+				directives = append(directives, d)
+			}
+		}
+	}
+	return directives
+}
+
+// isValuedDirective reports whether name is a robots directive that takes a
+// value rather than a user agent prefix
+func isValuedDirective(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "unavailable_after", "max-snippet", "max-image-preview", "max-video-preview":
+		return true
+	}
+	return false
+}
+
+// applyRobotsDirectives sets the effective indexability and followability of
+// page from its meta robots and X-Robots-Tag directives
+func applyRobotsDirectives(page *models.Page) {
+	directives := append(append([]string(nil), page.MetaRobots...), page.XRobotsTag...)
+	ok := page.StatusCode >= 200 && page.StatusCode < 300
+	page.Indexable = ok && !hasDirective(directives, "noindex", "none")
+	page.Followable = !hasDirective(directives, "nofollow", "none")
+}
+
+// nofollowSource names the directive source that made page unfollowable
+func nofollowSource(page *models.Page) string {
+	if hasDirective(page.MetaRobots, "nofollow", "none") {
+		return models.BlockedByMetaRobots
+	}
+	return models.BlockedByXRobotsTag
+}
+
+// hasDirective reports whether any of names appears in directives
+func hasDirective(directives []string, names ...string) bool {
+	for _, d := range directives {
+		for _, name := range names {
+			if d == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNofollow reports whether a link's rel attribute contains nofollow
+func isNofollow(rel string) bool {
+	for _, token := range strings.Fields(strings.ToLower(rel)) {
+		if token == "nofollow" {
+			return true
+		}
+	}
+	return false
+}
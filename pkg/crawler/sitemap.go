@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+const (
+	// maxSitemapSize is the uncompressed size limit from sitemaps.org
+	maxSitemapSize = 50 << 20
+	// maxSitemapFiles bounds how many sitemap documents one crawl reads
+	maxSitemapFiles = 100
+	// maxSitemapEntries bounds how many URLs are collected across sitemaps
+	maxSitemapEntries = 100000
+)
+
+var errNotSitemap = errors.New("document is not a sitemap")
+
+// wellKnownSitemaps are probed when robots.txt declares no sitemap
+var wellKnownSitemaps = []string{"/sitemap.xml", "/sitemap_index.xml"}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc is a <url> or <sitemap> element
+type sitemapLoc struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod"`
+	Priority string `xml:"priority"`
+}
+
+// discoverSitemaps finds sitemaps through robots.txt or the usual locations
+// and returns every in-scope URL they list, highest priority first
+func (c *WebCrawler) discoverSitemaps(ctx context.Context, run *crawlRun) []models.SitemapEntry {
+	candidates := run.robotsFor(c.startURL).sitemaps()
+	if len(candidates) == 0 {
+		for _, p := range wellKnownSitemaps {
+			u := url.URL{Scheme: c.startURL.Scheme, Host: c.startURL.Host, Path: p}
+			candidates = append(candidates, u.String())
+		}
+	}
+
+	var entries []models.SitemapEntry
+	visited := make(map[string]bool)
+	queue := candidates
+	for len(queue) > 0 && len(visited) < maxSitemapFiles && len(entries) < maxSitemapEntries {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if visited[sitemapURL] {
+			continue
+		}
+		visited[sitemapURL] = true
+
+		doc, err := c.fetchSitemap(ctx, sitemapURL, run.opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		run.result.Sitemaps = append(run.result.Sitemaps, sitemapURL)
+
+		for _, child := range doc.Sitemaps {
+			if loc := strings.TrimSpace(child.Loc); loc != "" {
+				queue = append(queue, loc)
+			}
+		}
+		for _, loc := range doc.URLs {
+			u, err := url.Parse(strings.TrimSpace(loc.Loc))
+			if err != nil {
+				continue
+			}
+			run.addSubdomain(u.Hostname(), models.SubdomainSourceSitemap)
+			if !run.inScope(u) {
+				continue
+			}
+			entry := models.SitemapEntry{
+				URL:     run.canon.URL(u),
+				LastMod: strings.TrimSpace(loc.LastMod),
+				Sitemap: sitemapURL,
+			}
+			if p, err := strconv.ParseFloat(strings.TrimSpace(loc.Priority), 64); err == nil {
+				entry.Priority = p
+			}
+			entries = append(entries, entry)
+			if len(entries) >= maxSitemapEntries {
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Priority > entries[j].Priority
+	})
+	return entries
+}
+
+// fetchSitemap downloads and decodes one sitemap, gunzipping it if needed
+func (c *WebCrawler) fetchSitemap(ctx context.Context, sitemapURL string, opts Options) (*sitemapDocument, error) {
+	status, body, err := c.get(ctx, sitemapURL, opts, maxSitemapSize)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d for %s", status, sitemapURL)
+	}
+	return parseSitemap(body)
+}
+
+// parseSitemap decodes a urlset or sitemapindex document. Gzipped input is
+// detected by its magic bytes, since servers rarely label it correctly.
+func parseSitemap(body []byte) (*sitemapDocument, error) {
+	var r io.Reader = bytes.NewReader(body)
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = io.LimitReader(gz, maxSitemapSize)
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+		return &doc, nil
+	}
+	return nil, errNotSitemap
+}
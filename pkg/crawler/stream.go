@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// EventType says what a crawl Event reports
+type EventType string
+
+// Crawl event types
+const (
+	EventPage  EventType = "page"  // A page was fetched and kept
+	EventError EventType = "error" // A URL could not be fetched
+	EventSkip  EventType = "skip"  // A discovered URL will not be fetched
+	EventDone  EventType = "done"  // The crawl ended; always the last event
+)
+
+// Reasons a URL is skipped, besides the robots sources in models.BlockedBy*
+// and the trap heuristics in models.Trap*
+const (
+	SkipExcluded  = "excluded"   // Matched an exclude pattern
+	SkipPathLimit = "path-limit" // Its path pattern had no budget left
+	SkipDuplicate = "duplicate"  // Fetched, but its content matched an earlier page
+)
+
+// Event is one step of a streamed crawl
+type Event struct {
+	Type  EventType
+	URL   string
+	Depth int
+
+	Page   *models.Page        // EventPage
+	Err    error               // EventError, and EventDone when the crawl failed
+	Reason string              // EventSkip
+	Result *models.CrawlResult // EventDone: the summary, without pages
+}
+
+// OnPage registers fn to be called with every page as soon as it is crawled.
+// Hooks run on the crawl's dispatcher, so a slow hook slows the crawl down.
+func (c *WebCrawler) OnPage(fn func(page *models.Page)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onPage = fn
+}
+
+// OnError registers fn to be called for every URL that fails to fetch
+func (c *WebCrawler) OnError(fn func(url string, err error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onError = fn
+}
+
+// OnSkip registers fn to be called for every discovered URL that will not be
+// fetched, with one of the Skip reasons, a models.BlockedBy source or a
+// models.Trap heuristic. A URL skipped through one link can still be crawled
+// through another.
+func (c *WebCrawler) OnSkip(fn func(url, reason string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSkip = fn
+}
+
+// Stream crawls in the background and delivers pages, errors and skips on
+// the returned channel as they happen, finishing with an EventDone. Pages
+// are not collected into the result, so memory does not grow with the size
+// of the site. The channel must be drained until it is closed, or ctx
+// cancelled to stop the crawl.
+func (c *WebCrawler) Stream(ctx context.Context) <-chan Event {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		result, err := c.crawl(ctx, true, func(ev Event) {
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		}, nil)
+		select {
+		case events <- Event{Type: EventDone, Err: err, Result: result}:
+		case <-ctx.Done():
+		}
+	}()
+	return events
+}
+
+// emitter combines the registered hooks and send, if set, into one callback
+func (c *WebCrawler) emitter(send func(Event)) func(Event) {
+	c.mu.Lock()
+	onPage, onError, onSkip := c.onPage, c.onError, c.onSkip
+	c.mu.Unlock()
+	if onPage == nil && onError == nil && onSkip == nil && send == nil {
+		return nil
+	}
+
+	return func(ev Event) {
+		switch {
+		case ev.Type == EventPage && onPage != nil:
+			onPage(ev.Page)
+		case ev.Type == EventError && onError != nil:
+			onError(ev.URL, ev.Err)
+		case ev.Type == EventSkip && onSkip != nil:
+			onSkip(ev.URL, ev.Reason)
+		}
+		if send != nil {
+			send(ev)
+		}
+	}
+}
+
+// emit reports ev to the hooks and stream of the run, if any
+func (r *crawlRun) emit(ev Event) {
+	if r.events != nil {
+		r.events(ev)
+	}
+}
+
+// skip reports that u, found at depth, will not be fetched
+func (r *crawlRun) skip(u string, depth int, reason string) {
+	r.emit(Event{Type: EventSkip, URL: u, Depth: depth, Reason: reason})
+}
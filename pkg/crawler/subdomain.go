@@ -0,0 +1,253 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+const (
+	// lookupTimeout bounds each DNS lookup and the TLS certificate probe
+	lookupTimeout = 5 * time.Second
+	// maxLookups is how many DNS lookups run at once
+	maxLookups = 8
+)
+
+// SetResolver sets the DNS resolver used to fetch pages and to check
+// discovered subdomains. Call it before crawling.
+func (c *WebCrawler) SetResolver(r *net.Resolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolver = r
+	c.setTransport()
+}
+
+// addSubdomain records host as a subdomain candidate found through source,
+// ignoring anything that is not below the seed's domain
+func (r *crawlRun) addSubdomain(host, source string) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == r.seed.Hostname() || !isSubdomainOf(host, baseDomain(r.seed.Hostname())) {
+		return
+	}
+	if r.subdomains[host] == nil {
+		r.subdomains[host] = make(map[string]bool)
+	}
+	r.subdomains[host][source] = true
+}
+
+// headerSubdomains collects hosts named in CSP and CORS response headers
+func (r *crawlRun) headerSubdomains(header map[string][]string) {
+	h := http.Header(header)
+	for _, name := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		for _, value := range h.Values(name) {
+			for _, host := range policyHosts(value) {
+				r.addSubdomain(host, models.SubdomainSourceCSP)
+			}
+		}
+	}
+	for _, value := range h.Values("Access-Control-Allow-Origin") {
+		for _, host := range policyHosts(value) {
+			r.addSubdomain(host, models.SubdomainSourceCORS)
+		}
+	}
+}
+
+// policyHosts extracts host names from CSP source lists or CORS origins.
+// Keywords, schemes and wildcard hosts are skipped.
+func policyHosts(value string) []string {
+	var hosts []string
+	for _, token := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ';' || r == ',' || r == '\t'
+	}) {
+		if strings.HasPrefix(token, "'") || strings.HasSuffix(token, ":") || !strings.Contains(token, ".") {
+			continue
+		}
+		if !strings.Contains(token, "://") {
+			token = "https://" + token
+		}
+		u, err := url.Parse(token)
+		if err != nil || strings.HasPrefix(u.Hostname(), "*") || u.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, u.Hostname())
+	}
+	return hosts
+}
+
+// probeSubdomains looks for subdomains before the crawl starts: in the
+// seed's TLS certificate and by resolving the configured wordlist. Both probe
+// hosts the crawl was not pointed at, so they only run with IncludeSubdomains.
+func (c *WebCrawler) probeSubdomains(ctx context.Context, run *crawlRun) {
+	if !run.opts.IncludeSubdomains {
+		return
+	}
+	names := c.certificateNames(ctx)
+	for _, name := range names {
+		run.addSubdomain(name, models.SubdomainSourceTLS)
+	}
+
+	domain := baseDomain(run.seed.Hostname())
+	wordlist := make(map[string]bool)
+	for _, word := range run.opts.SubdomainWordlist {
+		if word = strings.Trim(strings.ToLower(strings.TrimSpace(word)), "."); word != "" {
+			wordlist[word+"."+domain] = true
+		}
+	}
+
+	candidates := make([]string, 0, len(run.subdomains)+len(wordlist))
+	for host := range run.subdomains {
+		candidates = append(candidates, host)
+	}
+	for host := range wordlist {
+		candidates = append(candidates, host)
+	}
+	for host, addrs := range c.lookupHosts(ctx, candidates) {
+		if wordlist[host] {
+			if len(addrs) == 0 {
+				continue
+			}
+			run.addSubdomain(host, models.SubdomainSourceWordlist)
+		}
+		run.addresses[host] = addrs
+	}
+}
+
+// probedRoots returns the root URLs of probed subdomains that resolve, using
+// the seed's scheme and port, so they can be crawled when in scope
+func (r *crawlRun) probedRoots() []*url.URL {
+	var roots []*url.URL
+	for host, addrs := range r.addresses {
+		if len(addrs) == 0 {
+			continue
+		}
+		if port := r.seed.Port(); port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		roots = append(roots, &url.URL{Scheme: r.seed.Scheme, Host: host, Path: "/"})
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Host < roots[j].Host
+	})
+	return roots
+}
+
+// certificateNames returns the DNS names in the certificate the seed host
+// presents on its HTTPS port. Failures just mean no names. The probe is a
+// direct connection, so it is skipped when requests go through proxies.
+func (c *WebCrawler) certificateNames(ctx context.Context) []string {
+	c.mu.Lock()
+	dial, proxied := c.dial, c.proxies != nil
+	c.mu.Unlock()
+	if proxied {
+		return nil
+	}
+
+	port := "443"
+	if c.startURL.Scheme == "https" && c.startURL.Port() != "" {
+		port = c.startURL.Port()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	raw, err := dial(ctx, "tcp", net.JoinHostPort(c.startURL.Hostname(), port))
+	if err != nil {
+		return nil
+	}
+	// Only the names are read; whether the certificate is trusted does not
+	// matter here
+	conn := tls.Client(raw, &tls.Config{ServerName: c.startURL.Hostname(), InsecureSkipVerify: true})
+	defer conn.Close()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil
+	}
+	var names []string
+	for _, name := range certs[0].DNSNames {
+		if !strings.HasPrefix(name, "*") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lookupHosts resolves hosts concurrently; hosts that do not resolve map to nil
+func (c *WebCrawler) lookupHosts(ctx context.Context, hosts []string) map[string][]string {
+	resolver := c.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string][]string, len(hosts))
+	sem := make(chan struct{}, maxLookups)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+
+			lookupCtx, cancel := context.WithTimeout(ctx, lookupTimeout)
+			defer cancel()
+			addrs, err := resolver.LookupHost(lookupCtx, host)
+			if err != nil {
+				addrs = nil
+			}
+			mu.Lock()
+			results[host] = addrs
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return results
+}
+
+// subdomainReport resolves every candidate not yet looked up and reports how
+// each was found, whether it resolves and whether any of its pages were crawled
+func (c *WebCrawler) subdomainReport(ctx context.Context, run *crawlRun) []models.Subdomain {
+	var pending []string
+	for host := range run.subdomains {
+		if _, ok := run.addresses[host]; !ok {
+			pending = append(pending, host)
+		}
+	}
+	for host, addrs := range c.lookupHosts(ctx, pending) {
+		run.addresses[host] = addrs
+	}
+
+	report := make([]models.Subdomain, 0, len(run.subdomains))
+	for host, sources := range run.subdomains {
+		s := models.Subdomain{
+			Host:      host,
+			Addresses: run.addresses[host],
+			Resolves:  len(run.addresses[host]) > 0,
+			Crawled:   run.hosts[host],
+		}
+		for source := range sources {
+			s.Sources = append(s.Sources, source)
+		}
+		sort.Strings(s.Sources)
+		report = append(report, s)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Host < report[j].Host
+	})
+	return report
+}
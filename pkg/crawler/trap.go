@@ -0,0 +1,238 @@
+package crawler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// TrapOptions tunes crawler trap detection. A limit of zero disables its check.
+type TrapOptions struct {
+	MaxPathDepth      int // Path segments a URL may have
+	MaxSegmentRepeats int // Times one segment may occur in a path
+	MaxQueryParams    int // Query parameters a URL may have
+	MaxCounterSteps   int // Times a numeric query parameter may be counted on past the values seen so far
+	MaxNearDuplicates int // Near-identical pages per path pattern before the pattern is quarantined
+}
+
+// nearDuplicateDistance is the most simhash bits two pages may differ in to
+// count as near-identical
+const nearDuplicateDistance = 3
+
+// maxFingerprints caps the distinct fingerprints kept per path pattern
+const maxFingerprints = 64
+
+// trapDetector spots URL spaces that never end: calendars, session IDs and
+// nested relative links in paths, and faceted navigation
+type trapDetector struct {
+	opts     TrapOptions
+	counters map[string]*counterRange
+	patterns map[string]*patternPages
+}
+
+// counterRange is the span of values seen for one numeric query parameter
+type counterRange struct {
+	min, max int64
+	steps    int
+}
+
+// patternPages tracks the content fingerprints of one path pattern
+type patternPages struct {
+	fingerprints []uint64
+	duplicates   int
+}
+
+// newTrapDetector creates a detector with the given limits
+func newTrapDetector(opts TrapOptions) *trapDetector {
+	return &trapDetector{
+		opts:     opts,
+		counters: make(map[string]*counterRange),
+		patterns: make(map[string]*patternPages),
+	}
+}
+
+// check returns the trap heuristic u, linked from the page at from, trips,
+// with a short explanation, or an empty reason. Numeric query parameters are
+// tracked as a side effect, so each URL must be checked once.
+func (d *trapDetector) check(u, from *url.URL) (reason, detail string) {
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	if d.opts.MaxPathDepth > 0 && len(segments) > d.opts.MaxPathDepth {
+		return models.TrapPathDepth, fmt.Sprintf("%d path segments", len(segments))
+	}
+	if d.opts.MaxSegmentRepeats > 0 {
+		counts := make(map[string]int, len(segments))
+		for _, segment := range segments {
+			segment = strings.ToLower(segment)
+			if counts[segment]++; counts[segment] > d.opts.MaxSegmentRepeats {
+				return models.TrapRepeatingPath, fmt.Sprintf("segment %q repeats %d times", segment, counts[segment])
+			}
+		}
+	}
+
+	if p := d.patterns[patternKey(u)]; p != nil && d.opts.MaxNearDuplicates > 0 && p.duplicates >= d.opts.MaxNearDuplicates {
+		return models.TrapNearDuplicate, fmt.Sprintf("%d near-identical pages under %s", p.duplicates, PathPattern(u))
+	}
+
+	query := u.Query()
+	if d.opts.MaxQueryParams > 0 && len(query) > d.opts.MaxQueryParams {
+		return models.TrapQueryParams, fmt.Sprintf("%d query parameters", len(query))
+	}
+	if d.opts.MaxCounterSteps > 0 {
+		return d.checkCounters(u, query, from)
+	}
+	return "", ""
+}
+
+// checkCounters follows every numeric query parameter of u. Calendars and
+// endless pagination link each page to the next value, so a parameter counts
+// on when the page at from carries the highest or lowest value seen so far
+// and links past it. Values that merely differ, such as the ?id= of catalog
+// links, never count. Once a parameter has counted on MaxCounterSteps times,
+// URLs taking it further are traps.
+func (d *trapDetector) checkCounters(u *url.URL, query url.Values, from *url.URL) (reason, detail string) {
+	prefix, names := counterPrefix(u, query)
+	var previous url.Values
+	if from != nil {
+		fromQuery := from.Query()
+		if fromPrefix, _ := counterPrefix(from, fromQuery); fromPrefix == prefix {
+			previous = fromQuery
+		}
+	}
+
+	for _, name := range names {
+		n, ok := counterValue(query.Get(name))
+		if !ok {
+			continue
+		}
+		key := prefix + name
+		c := d.counters[key]
+		if c == nil {
+			d.counters[key] = &counterRange{min: n, max: n}
+			continue
+		}
+		if n >= c.min && n <= c.max {
+			continue
+		}
+		prev, ok := counterValue(previous.Get(name))
+		if ok && ((n > c.max && prev == c.max) || (n < c.min && prev == c.min)) {
+			if c.steps >= d.opts.MaxCounterSteps {
+				return models.TrapCounter, fmt.Sprintf("parameter %q kept counting past %d values", name, c.steps+1)
+			}
+			c.steps++
+		}
+		c.min, c.max = min(c.min, n), max(c.max, n)
+	}
+	return "", ""
+}
+
+// counterPrefix returns the key that groups the counters of URLs with the
+// same host, path and query parameter names, and the sorted names
+func counterPrefix(u *url.URL, query url.Values) (string, []string) {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.ToLower(u.Host) + u.Path + "?" + strings.Join(names, "&") + "#", names
+}
+
+// counterValue reads the digits of a parameter value as one number, so
+// dates such as 2024-05 count up as well as plain integers
+func counterValue(value string) (int64, bool) {
+	var digits strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if digits.Len() == 0 || digits.Len() > 18 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(digits.String(), 10, 64)
+	return n, err == nil
+}
+
+// observe records the content fingerprint of a fetched page. Once a path
+// pattern has served MaxNearDuplicates pages near-identical to earlier ones,
+// further URLs of the pattern are quarantined by check.
+func (d *trapDetector) observe(page *models.Page) {
+	if page.Fingerprint == "" || d.opts.MaxNearDuplicates <= 0 {
+		return
+	}
+	fp, err := strconv.ParseUint(page.Fingerprint, 16, 64)
+	if err != nil {
+		return
+	}
+	u, err := url.Parse(page.URL)
+	if err != nil {
+		return
+	}
+
+	key := patternKey(u)
+	p := d.patterns[key]
+	if p == nil {
+		p = &patternPages{}
+		d.patterns[key] = p
+	}
+	for _, seen := range p.fingerprints {
+		if bits.OnesCount64(seen^fp) <= nearDuplicateDistance {
+			p.duplicates++
+			return
+		}
+	}
+	if len(p.fingerprints) < maxFingerprints {
+		p.fingerprints = append(p.fingerprints, fp)
+	}
+}
+
+// patternKey groups URLs by host and path pattern
+func patternKey(u *url.URL) string {
+	return strings.ToLower(u.Host) + PathPattern(u)
+}
+
+// fingerprint returns the 64-bit simhash of text over three-word shingles,
+// in hex, or an empty string when there is no text
+func fingerprint(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	const shingle = 3
+	var weights [64]int
+	for i := 0; i == 0 || i+shingle <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:min(i+shingle, len(words))], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fp uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fp |= 1 << bit
+		}
+	}
+	return strconv.FormatUint(fp, 16)
+}
+
+// quarantine records u as a suspected trap instead of crawling it
+func (r *crawlRun) quarantine(key string, depth int, reason, detail string) {
+	r.trapped = append(r.trapped, models.QuarantinedURL{URL: key, Reason: reason, Detail: detail})
+	r.skip(key, depth, reason)
+}
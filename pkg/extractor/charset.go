@@ -0,0 +1,170 @@
+package extractor
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// metaPrescanSize is how far into an HTML document a <meta> charset
+// declaration is looked for, as in browsers
+const metaPrescanSize = 1024
+
+// sniffSize caps how much of an undeclared body is decoded per candidate
+// charset when sniffing
+const sniffSize = 64 << 10
+
+// sniffCharsets are the candidates for bytes that are neither declared nor
+// valid UTF-8. EUC-KR and Big5 share too many byte sequences with GBK to be
+// told apart without a declaration.
+var sniffCharsets = []string{"shift_jis", "euc-jp", "gbk", "windows-1251", "koi8-r", "windows-1252"}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// DecodeCharset transcodes body to UTF-8 and returns it with the canonical
+// name of its charset. The charset is taken from a byte order mark, the
+// charset parameter of contentType, a <meta> declaration near the start of an
+// HTML document or, failing those, sniffed from the bytes.
+func DecodeCharset(body []byte, contentType string) ([]byte, string) {
+	name := declaredCharset(body, contentType)
+	if name == "" {
+		name = sniffCharset(body)
+	}
+	if name != "utf-8" {
+		enc, _ := charset.Lookup(name)
+		if decoded, err := enc.NewDecoder().Bytes(body); err == nil {
+			body = decoded
+		}
+	}
+	return bytes.TrimPrefix(body, utf8BOM), name
+}
+
+// declaredCharset returns the charset a body declares, or an empty string
+func declaredCharset(body []byte, contentType string) string {
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if _, name := charset.Lookup(params["charset"]); name != "" {
+		return name
+	}
+	if mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return metaCharset(body[:min(len(body), metaPrescanSize)])
+	}
+	return ""
+}
+
+// metaCharset returns the charset of the first <meta charset> or
+// <meta http-equiv="Content-Type"> tag in an HTML fragment
+func metaCharset(prefix []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(prefix))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data != "meta" {
+				continue
+			}
+			var label, httpEquiv, content string
+			for _, attr := range tok.Attr {
+				switch attr.Key {
+				case "charset":
+					label = attr.Val
+				case "http-equiv":
+					httpEquiv = attr.Val
+				case "content":
+					content = attr.Val
+				}
+			}
+			if label == "" && strings.EqualFold(strings.TrimSpace(httpEquiv), "content-type") {
+				_, params, _ := mime.ParseMediaType(content)
+				label = params["charset"]
+			}
+			if _, name := charset.Lookup(label); name != "" {
+				// A document whose markup could be read as ASCII is not UTF-16
+				if strings.HasPrefix(name, "utf-16") {
+					name = "utf-8"
+				}
+				return name
+			}
+		}
+	}
+}
+
+// sniffCharset guesses the charset of undeclared bytes: UTF-8 when they are
+// valid UTF-8, otherwise the candidate whose decoding reads most like text,
+// with windows-1252 as the fallback browsers use
+func sniffCharset(body []byte) string {
+	if utf8.Valid(body) {
+		return "utf-8"
+	}
+	sample := body[:min(len(body), sniffSize)]
+	best, bestScore := "windows-1252", 0
+	for _, name := range sniffCharsets {
+		enc, _ := charset.Lookup(name)
+		decoded, err := enc.NewDecoder().Bytes(sample)
+		if err != nil {
+			continue
+		}
+		if score := textScore([]rune(string(decoded))); score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	return best
+}
+
+// textScore rates how much decoded text looks like natural language. A wrong
+// charset shows as replacement and control characters, half-width katakana,
+// box drawing, Cyrillic letters inside Latin words and runs of accented
+// letters with no plain ones around them.
+func textScore(text []rune) int {
+	score := 0
+	for i, r := range text {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		latinNeighbor := (i > 0 && isASCIILetter(text[i-1])) || (i+1 < len(text) && isASCIILetter(text[i+1]))
+		switch {
+		case r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Co, r):
+			score -= 5
+		case r >= 0xFF61 && r <= 0xFF9F:
+			// Half-width katakana, rare in modern text
+			score--
+		case r >= 0x2500 && r <= 0x259F:
+			score--
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			score += 2
+		case unicode.Is(unicode.Han, r):
+			score++
+		case unicode.Is(unicode.Cyrillic, r):
+			switch {
+			case latinNeighbor:
+				score -= 2
+			case unicode.IsLower(r):
+				score += 2
+			default:
+				score++
+			}
+		case unicode.Is(unicode.Latin, r) && latinNeighbor:
+			score++
+		}
+	}
+	return score
+}
+
+func isASCIILetter(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
@@ -0,0 +1,43 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		charset     string
+		text        string
+	}{
+		{"UTF-8 BOM beats the header", "\xEF\xBB\xBFcaf\xC3\xA9", "text/html; charset=windows-1252", "utf-8", "café"},
+		{"UTF-16LE BOM", "\xFF\xFEc\x00a\x00f\x00\xE9\x00", "text/html", "utf-16le", "café"},
+		{"UTF-16BE BOM", "\xFE\xFF\x00c\x00a\x00f\x00\xE9", "", "utf-16be", "café"},
+		{"header", "caf\xE9", "text/plain; charset=ISO-8859-1", "windows-1252", "café"},
+		{"header beats meta", `<meta charset="utf-8">caf` + "\xE9", "text/html; charset=latin1", "windows-1252", `<meta charset="utf-8">café`},
+		{"unknown header charset falls back to meta", "<meta charset=windows-1251>\xEF\xF0\xE8\xE2\xE5\xF2", "text/html; charset=bogus", "windows-1251", "<meta charset=windows-1251>привет"},
+		{"meta charset", "<html><head><meta charset=' Shift_JIS '></head>\x82\xB1\x82\xF1", "text/html", "shift_jis", "<html><head><meta charset=' Shift_JIS '></head>こん"},
+		{"meta http-equiv", `<meta http-equiv="content-type" content="text/html; charset=koi8-r">` + "\xD0\xD2\xC9", "", "koi8-r", `<meta http-equiv="content-type" content="text/html; charset=koi8-r">при`},
+		{"meta UTF-16 is read as UTF-8", "<meta charset=utf-16>caf\xC3\xA9", "text/html", "utf-8", "<meta charset=utf-16>café"},
+		{"meta ignored outside HTML", "<meta charset=windows-1251>caf\xC3\xA9", "text/plain", "utf-8", "<meta charset=windows-1251>café"},
+		{"meta past the prescan", strings.Repeat(" ", metaPrescanSize) + "<meta charset=windows-1251>", "text/html", "utf-8", strings.Repeat(" ", metaPrescanSize) + "<meta charset=windows-1251>"},
+		{"undeclared UTF-8", "caf\xC3\xA9 \xE6\x97\xA5\xE6\x9C\xAC", "text/html", "utf-8", "café 日本"},
+		{"undeclared Latin", "Caf\xE9 cr\xE8me br\xFBl\xE9e", "text/html", "windows-1252", "Café crème brûlée"},
+		{"undeclared Cyrillic", "\xEF\xF0\xE8\xE2\xE5\xF2 \xEC\xE8\xF0", "", "windows-1251", "привет мир"},
+		{"undeclared Japanese", "\x82\xB1\x82\xF1\x82\xC9\x82\xBF\x82\xCD\x90\xA2\x8A\x45", "text/html", "shift_jis", "こんにちは世界"},
+		{"undeclared fallback", "Wait\x85", "", "windows-1252", "Wait…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, name := DecodeCharset([]byte(tt.body), tt.contentType)
+			assert.Equal(t, tt.charset, name)
+			assert.Equal(t, tt.text, string(decoded))
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// maxDocumentPart caps how much of one file inside a DOCX archive is read
+const maxDocumentPart = 20 << 20
+
+// bareURL matches web addresses written out in document text
+var bareURL = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+[^\s<>"'()\[\]{}.,;:!?]`)
+
+// Document is the content of a non-HTML file
+type Document struct {
+	Type  string // One of the models.Document* types
+	Title string
+	Text  string
+	Links []Link
+}
+
+// DocumentType returns the models.Document* type of a response from its
+// Content-Type, falling back to the URL's extension when the server sends a
+// generic type. It returns an empty string for unsupported documents.
+func DocumentType(contentType, rawURL string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html", "application/xhtml+xml":
+		return models.DocumentHTML
+	case "application/pdf", "application/x-pdf":
+		return models.DocumentPDF
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return models.DocumentDOCX
+	case "text/plain":
+		return models.DocumentText
+	case "", "application/octet-stream", "binary/octet-stream", "application/download", "application/force-download":
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return ""
+		}
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".pdf":
+			return models.DocumentPDF
+		case ".docx":
+			return models.DocumentDOCX
+		case ".txt":
+			return models.DocumentText
+		}
+	}
+	return ""
+}
+
+// ExtractDocument pulls the text, title and links out of a PDF, DOCX or plain
+// text file. Links are resolved against pageURL; besides real hyperlinks they
+// include web addresses written out in the text.
+func (e *Extractor) ExtractDocument(docType string, body []byte, pageURL string) (*Document, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %q: %w", pageURL, err)
+	}
+
+	doc := &Document{Type: docType}
+	var hrefs []Link
+	switch docType {
+	case models.DocumentPDF:
+		err = extractPDF(doc, body, &hrefs)
+	case models.DocumentDOCX:
+		err = extractDOCX(doc, body, &hrefs)
+	case models.DocumentText:
+		doc.Text = string(body)
+	default:
+		return nil, fmt.Errorf("unsupported document type %q", docType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Text = strings.TrimSpace(doc.Text)
+	if doc.Title == "" {
+		doc.Title = firstLine(doc.Text)
+	}
+	for _, match := range bareURL.FindAllString(doc.Text, -1) {
+		hrefs = append(hrefs, Link{URL: match})
+	}
+	seen := make(map[string]bool)
+	for _, link := range hrefs {
+		u, err := base.Parse(cleanHref(link.URL))
+		if err != nil || link.URL == "" || seen[u.String()] {
+			continue
+		}
+		seen[u.String()] = true
+		link.URL = u.String()
+		link.AnchorText = strings.TrimSpace(link.AnchorText)
+		link.Scheme = strings.ToLower(u.Scheme)
+		link.Scope = linkScope(base, u)
+		doc.Links = append(doc.Links, link)
+	}
+	return doc, nil
+}
+
+// extractPDF reads the text of every page, the document title and the URIs
+// of link annotations. The PDF reader panics on malformed files, which is
+// turned into an error.
+func extractPDF(doc *Document, body []byte, links *[]Link) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to parse PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("failed to parse PDF: %w", err)
+	}
+	doc.Title = strings.TrimSpace(r.Trailer().Key("Info").Key("Title").Text())
+
+	var text strings.Builder
+	for i := 1; i <= r.NumPage(); i++ {
+		p := r.Page(i)
+		if p.V.IsNull() {
+			continue
+		}
+		if content, err := p.GetPlainText(nil); err == nil {
+			text.WriteString(content)
+			text.WriteString("\n")
+		}
+		annots := p.V.Key("Annots")
+		for j := 0; j < annots.Len(); j++ {
+			a := annots.Index(j)
+			if a.Key("Subtype").Name() != "Link" {
+				continue
+			}
+			if uri := a.Key("A").Key("URI").RawString(); uri != "" {
+				*links = append(*links, Link{URL: uri})
+			}
+		}
+	}
+	doc.Text = text.String()
+	return nil
+}
+
+// extractDOCX reads the body text, the core properties title and the
+// external hyperlinks of a Word document
+func extractDOCX(doc *Document, body []byte, links *[]Link) error {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	part := func(name string) ([]byte, error) {
+		f, err := zr.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, maxDocumentPart))
+	}
+
+	document, err := part("word/document.xml")
+	if err != nil {
+		return fmt.Errorf("failed to read DOCX: %w", err)
+	}
+
+	// Hyperlinks point at relationships that hold the actual targets
+	targets := make(map[string]string)
+	if rels, err := part("word/_rels/document.xml.rels"); err == nil {
+		var parsed struct {
+			Relationships []struct {
+				ID         string `xml:"Id,attr"`
+				Target     string `xml:"Target,attr"`
+				TargetMode string `xml:"TargetMode,attr"`
+			} `xml:"Relationship"`
+		}
+		if xml.Unmarshal(rels, &parsed) == nil {
+			for _, rel := range parsed.Relationships {
+				if rel.TargetMode == "External" {
+					targets[rel.ID] = rel.Target
+				}
+			}
+		}
+	}
+	if core, err := part("docProps/core.xml"); err == nil {
+		var parsed struct {
+			Title string `xml:"title"`
+		}
+		if xml.Unmarshal(core, &parsed) == nil {
+			doc.Title = strings.TrimSpace(parsed.Title)
+		}
+	}
+
+	var text strings.Builder
+	var link *Link
+	d := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse DOCX: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tab":
+				text.WriteString("\t")
+			case "br", "cr":
+				text.WriteString("\n")
+			case "hyperlink":
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "id" && targets[attr.Value] != "" {
+						link = &Link{URL: targets[attr.Value]}
+					}
+				}
+			case "t":
+				var s string
+				if err := d.DecodeElement(&s, &t); err != nil {
+					return fmt.Errorf("failed to parse DOCX: %w", err)
+				}
+				text.WriteString(s)
+				if link != nil {
+					link.AnchorText += s
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				text.WriteString("\n")
+			case "hyperlink":
+				if link != nil {
+					*links = append(*links, *link)
+					link = nil
+				}
+			}
+		}
+	}
+	doc.Text = text.String()
+	return nil
+}
+
+// firstLine returns the first non-empty line of text, for documents without
+// a title of their own
+func firstLine(text string) string {
+	const maxTitle = 200
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > maxTitle {
+				line = strings.TrimSpace(line[:strings.LastIndex(line[:maxTitle], " ")+1])
+			}
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package extractor
+
+import (
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+var (
+	// Cloudflare replaces addresses with an XOR-encoded hex string in a
+	// data-cfemail attribute or an email-protection link
+	cfEmailRegex = regexp.MustCompile(`(?:data-cfemail=["']?|/cdn-cgi/l/email-protection#)([0-9a-fA-F]{4,})`)
+	// "name [at] example [dot] com", with brackets, parentheses or braces
+	atDotRegex = regexp.MustCompile(`(?i)([a-z0-9._%+-]+)\s*[\[({]\s*at\s*[\])}]\s*([a-z0-9-]+(?:(?:\s*[\[({]\s*dot\s*[\])}]\s*|\.)[a-z0-9-]+)+)`)
+	dotRegex   = regexp.MustCompile(`(?i)\s*[\[({]\s*dot\s*[\])}]\s*`)
+)
+
+// assetExtensions are file extensions that follow an @ in asset names such as
+// logo@2x.png and are never mail domains
+var assetExtensions = map[string]bool{
+	"avif": true, "bmp": true, "css": true, "eot": true, "gif": true, "ico": true, "jpeg": true, "jpg": true,
+	"js": true, "json": true, "map": true, "mjs": true, "mp3": true, "mp4": true, "otf": true, "pdf": true,
+	"png": true, "svg": true, "tif": true, "tiff": true, "ttf": true, "webm": true, "webp": true, "woff": true,
+	"woff2": true, "xml": true, "zip": true,
+}
+
+// emailRoles maps the local parts of role addresses, without separators, to
+// their role
+var emailRoles = map[string]string{
+	"info": models.EmailRoleGeneral, "contact": models.EmailRoleGeneral, "hello": models.EmailRoleGeneral,
+	"office": models.EmailRoleGeneral, "enquiries": models.EmailRoleGeneral, "inquiries": models.EmailRoleGeneral,
+	"mail": models.EmailRoleGeneral, "team": models.EmailRoleGeneral,
+
+	"sales": models.EmailRoleSales, "orders": models.EmailRoleSales, "shop": models.EmailRoleSales,
+	"business": models.EmailRoleSales,
+
+	"support": models.EmailRoleSupport, "help": models.EmailRoleSupport, "helpdesk": models.EmailRoleSupport,
+	"service": models.EmailRoleSupport, "customerservice": models.EmailRoleSupport, "care": models.EmailRoleSupport,
+
+	"noreply": models.EmailRoleNoReply, "donotreply": models.EmailRoleNoReply, "mailerdaemon": models.EmailRoleNoReply,
+	"bounce": models.EmailRoleNoReply, "bounces": models.EmailRoleNoReply, "notifications": models.EmailRoleNoReply,
+
+	"admin": models.EmailRoleAdmin, "webmaster": models.EmailRoleAdmin, "postmaster": models.EmailRoleAdmin,
+	"hostmaster": models.EmailRoleAdmin, "abuse": models.EmailRoleAdmin, "security": models.EmailRoleAdmin,
+	"root": models.EmailRoleAdmin,
+
+	"hr": models.EmailRoleJobs, "jobs": models.EmailRoleJobs, "careers": models.EmailRoleJobs,
+	"recruiting": models.EmailRoleJobs, "recruitment": models.EmailRoleJobs,
+
+	"press": models.EmailRolePress, "media": models.EmailRolePress, "pr": models.EmailRolePress,
+	"marketing": models.EmailRolePress, "news": models.EmailRolePress, "newsletter": models.EmailRolePress,
+
+	"billing": models.EmailRoleBilling, "accounts": models.EmailRoleBilling, "accounting": models.EmailRoleBilling,
+	"invoices": models.EmailRoleBilling, "finance": models.EmailRoleBilling, "payments": models.EmailRoleBilling,
+
+	"legal": models.EmailRoleLegal, "privacy": models.EmailRoleLegal, "dpo": models.EmailRoleLegal,
+	"gdpr": models.EmailRoleLegal, "compliance": models.EmailRoleLegal,
+}
+
+// ExtractEmails finds the valid email addresses in content, decoding HTML
+// entities, Cloudflare email protection and "name [at] example [dot] com"
+// spellings. Addresses are lowercased, reported once and classified by role.
+func (e *Extractor) ExtractEmails(content string) []models.Email {
+	var emails []models.Email
+	for _, match := range cfEmailRegex.FindAllStringSubmatch(content, -1) {
+		emails = addEmail(emails, decodeCFEmail(match[1]))
+	}
+
+	text := html.UnescapeString(content)
+	for _, match := range e.emailRegex.FindAllString(text, -1) {
+		emails = addEmail(emails, match)
+	}
+	for _, match := range atDotRegex.FindAllStringSubmatch(text, -1) {
+		emails = addEmail(emails, match[1]+"@"+dotRegex.ReplaceAllString(match[2], "."))
+	}
+	return emails
+}
+
+// MailtoEmails returns the valid email addresses of mailto: links, including
+// several recipients and the to, cc and bcc fields of their query
+func MailtoEmails(links []Link) []models.Email {
+	var emails []models.Email
+	for _, link := range links {
+		if link.Scheme != "mailto" {
+			continue
+		}
+		u, err := url.Parse(link.URL)
+		if err != nil {
+			continue
+		}
+		recipients := strings.Split(u.Opaque, ",")
+		query := u.Query()
+		for _, field := range []string{"to", "cc", "bcc"} {
+			for _, v := range query[field] {
+				recipients = append(recipients, strings.Split(v, ",")...)
+			}
+		}
+		for _, r := range recipients {
+			if unescaped, err := url.PathUnescape(r); err == nil {
+				r = unescaped
+			}
+			emails = addEmail(emails, r)
+		}
+	}
+	return emails
+}
+
+// ValidEmail normalizes an email address and reports whether it is valid: a
+// local part and a domain whose top-level domain is in the public suffix
+// list and is not a file extension such as the png of logo@2x.png
+func ValidEmail(address string) (string, bool) {
+	address = strings.ToLower(strings.Trim(address, " \t\r\n.<>"))
+	local, domain, ok := strings.Cut(address, "@")
+	if !ok || local == "" || len(local) > 64 || len(domain) > 253 || strings.Contains(domain, "@") ||
+		strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") || strings.Contains(address, "..") {
+		return "", false
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	for _, label := range labels {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", false
+		}
+	}
+	tld := labels[len(labels)-1]
+	if assetExtensions[tld] {
+		return "", false
+	}
+	if _, icann := publicsuffix.PublicSuffix(tld); !icann {
+		return "", false
+	}
+	return address, true
+}
+
+// EmailRole returns the role of a role address such as info@, sales@ or
+// noreply@, or an empty string for personal addresses
+func EmailRole(address string) string {
+	local, _, _ := strings.Cut(strings.ToLower(address), "@")
+	local, _, _ = strings.Cut(local, "+")
+	local = strings.NewReplacer(".", "", "-", "", "_", "").Replace(local)
+	return emailRoles[local]
+}
+
+// decodeCFEmail decodes a Cloudflare-protected address: the first byte is
+// the key the rest are XORed with
+func decodeCFEmail(encoded string) string {
+	b, err := hex.DecodeString(encoded)
+	if err != nil || len(b) < 2 {
+		return ""
+	}
+	for i := 1; i < len(b); i++ {
+		b[i] ^= b[0]
+	}
+	return string(b[1:])
+}
+
+// addEmail adds address to emails when it is valid and not already there
+func addEmail(emails []models.Email, address string) []models.Email {
+	address, ok := ValidEmail(address)
+	if !ok {
+		return emails
+	}
+	for _, e := range emails {
+		if e.Address == address {
+			return emails
+		}
+	}
+	return append(emails, models.Email{Address: address, Role: EmailRole(address)})
+}
+
+// mergeEmails adds the addresses of mailto: links to those found in text
+func mergeEmails(text, mailtos []models.Email) []models.Email {
+	for _, m := range mailtos {
+		text = addEmail(text, m.Address)
+	}
+	return text
+}
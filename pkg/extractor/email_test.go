@@ -0,0 +1,81 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+func TestExtractEmails(t *testing.T) {
+	e := New()
+	content := `<p>Write to INFO@Example.com or info@example.com.</p>
+		<p>Sales: sales&#64;example.com</p>
+		<a href="/cdn-cgi/l/email-protection" data-cfemail="42282d2a2c02273a232f322e276c2d3025">[email&#160;protected]</a>
+		<p>jane [at] example [dot] co [dot] uk, press (at) example (dot) com</p>
+		<img src="logo@2x.png"> <span>user@example.notatld</span> <span>a..b@example.com</span>`
+
+	assert.Equal(t, []models.Email{
+		{Address: "john@example.org"},
+		{Address: "info@example.com", Role: models.EmailRoleGeneral},
+		{Address: "sales@example.com", Role: models.EmailRoleSales},
+		{Address: "jane@example.co.uk"},
+		{Address: "press@example.com", Role: models.EmailRolePress},
+	}, e.ExtractEmails(content))
+}
+
+func TestMailtoEmails(t *testing.T) {
+	links := []Link{
+		{URL: "mailto:Support@Example.com?subject=Hello%20there&body=Hi", Scheme: "mailto"},
+		{URL: "mailto:a@example.com,b@example.com?cc=c@example.com&bcc=d%40example.com", Scheme: "mailto"},
+		{URL: "mailto:?to=no-reply@example.com", Scheme: "mailto"},
+		{URL: "mailto:not-an-address", Scheme: "mailto"},
+		{URL: "https://example.com/contact@example.com", Scheme: "https"},
+	}
+	assert.Equal(t, []models.Email{
+		{Address: "support@example.com", Role: models.EmailRoleSupport},
+		{Address: "a@example.com"},
+		{Address: "b@example.com"},
+		{Address: "c@example.com"},
+		{Address: "d@example.com"},
+		{Address: "no-reply@example.com", Role: models.EmailRoleNoReply},
+	}, MailtoEmails(links))
+}
+
+func TestValidEmail(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string // Empty when the address is rejected
+	}{
+		{"John.Doe@Example.COM", "john.doe@example.com"},
+		{" <info@example.de>. ", "info@example.de"},
+		{"user+tag@mail.example.co.uk", "user+tag@mail.example.co.uk"},
+
+		{"logo@2x.png", ""},
+		{"icon@sprite.svg", ""},
+		{"user@localhost", ""},
+		{"user@example.notatld", ""},
+		{"@example.com", ""},
+		{"user.@example.com", ""},
+		{"us..er@example.com", ""},
+		{"user@-example.com", ""},
+		{"user@example@example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			got, ok := ValidEmail(tt.address)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEmailRole(t *testing.T) {
+	assert.Equal(t, models.EmailRoleGeneral, EmailRole("Info@example.com"))
+	assert.Equal(t, models.EmailRoleNoReply, EmailRole("do-not-reply@example.com"))
+	assert.Equal(t, models.EmailRoleSupport, EmailRole("support+tickets@example.com"))
+	assert.Equal(t, models.EmailRoleJobs, EmailRole("careers@example.com"))
+	assert.Equal(t, "", EmailRole("jane.doe@example.com"))
+}
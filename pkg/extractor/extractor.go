@@ -1,10 +1,14 @@
 package extractor
 
 import (
+	"net/url"
 	"regexp"
 	"strings"
 	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
 	"github.com/markusmobius/go-trafilatura"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
 )
 
 // Extractor handles content extraction from HTML
@@ -12,20 +16,35 @@ type Extractor struct {
 	emailRegex    *regexp.Regexp
 	phoneRegex    *regexp.Regexp
 	whatsappRegex *regexp.Regexp
+	twitterRegex  *regexp.Regexp
+	linkedinRegex *regexp.Regexp
 }
 
 // New creates a new Extractor instance
 func New() *Extractor {
 	return &Extractor{
 		emailRegex:    regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
-		phoneRegex:    regexp.MustCompile(`(?:\+?[1-9]\d{0,2}[\s.-]?)?\(?\d{1,4}\)?[\s.-]?\d{1,4}[\s.-]?\d{1,4}[\s.-]?\d{0,4}`),
-		whatsappRegex: regexp.MustCompile(`(?:whatsapp|wa\.me)/?\+?(\d{10,15})`),
+		phoneRegex:    regexp.MustCompile(`(?:\+|\()?\b\d[\d .\-/()]{5,22}\d\b`),
+		whatsappRegex: regexp.MustCompile(`(?i)(?:wa\.me/|whatsapp\.com/send\?phone=|whatsapp:?\s+)(\+?\d[\d\s().-]{6,18}\d)`),
+		twitterRegex:  regexp.MustCompile(`(?:twitter\.com|x\.com)/([a-zA-Z0-9_]+)`),
+		linkedinRegex: regexp.MustCompile(`linkedin\.com/in/([a-zA-Z0-9-]+)`),
 	}
 }
 
 // ExtractText extracts clean text from HTML using trafilatura
 func (e *Extractor) ExtractText(htmlContent string) (string, error) {
-	result, err := trafilatura.Extract(strings.NewReader(htmlContent), trafilatura.Options{})
+	doc, err := Parse(htmlContent, "")
+	if err != nil {
+		return "", err
+	}
+	return doc.MainText()
+}
+
+// MainText returns the main content of the document as found by trafilatura,
+// leaving out navigation, footers and other boilerplate. Trafilatura works on
+// a copy, so the document can still be used afterwards.
+func (d *HTMLDocument) MainText() (string, error) {
+	result, err := trafilatura.ExtractDocument(d.Root, trafilatura.Options{})
 	if err != nil {
 		return "", err
 	}
@@ -35,30 +54,74 @@ func (e *Extractor) ExtractText(htmlContent string) (string, error) {
 	return result.ContentText, nil
 }
 
+// ExtractVisibleText returns the text of all rendered nodes, skipping scripts
+// and styles. Unlike ExtractText it keeps boilerplate such as footers, which
+// is where contact details usually live.
+func (e *Extractor) ExtractVisibleText(htmlContent string) (string, error) {
+	doc, err := Parse(htmlContent, "")
+	if err != nil {
+		return "", err
+	}
+	return doc.VisibleText(), nil
+}
+
+// VisibleText returns the text of all rendered nodes of the document
+func (d *HTMLDocument) VisibleText() string {
+	var parts []string
+	var extract func(*html.Node)
+	extract = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			if text := strings.TrimSpace(n.Data); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+
+	extract(d.Root)
+	return strings.Join(parts, "\n")
+}
+
 // ExtractMetadata extracts meta tags from HTML
 func (e *Extractor) ExtractMetadata(htmlContent string) (title, description string, err error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
+	doc, err := Parse(htmlContent, "")
 	if err != nil {
 		return "", "", err
 	}
-	
+	title, description = doc.Metadata()
+	return title, description, nil
+}
+
+// Metadata returns the text of the document's first <title> and its meta
+// description
+func (d *HTMLDocument) Metadata() (title, description string) {
+	var hasTitle, hasDescription bool
 	var extract func(*html.Node)
 	extract = func(n *html.Node) {
 		if n.Type == html.ElementNode {
-			if n.Data == "title" && n.FirstChild != nil {
-				title = n.FirstChild.Data
-			} else if n.Data == "meta" {
+			// Inline SVG has <title> elements of its own
+			if n.Data == "title" && n.Namespace == "" && !hasTitle {
+				title, hasTitle = extractText(n), true
+			} else if n.Data == "meta" && !hasDescription {
 				var name, content string
 				for _, attr := range n.Attr {
-					if attr.Key == "name" && attr.Val == "description" {
-						name = attr.Val
+					if attr.Key == "name" {
+						name = strings.ToLower(strings.TrimSpace(attr.Val))
 					}
 					if attr.Key == "content" {
 						content = attr.Val
 					}
 				}
 				if name == "description" {
-					description = content
+					description, hasDescription = content, true
 				}
 			}
 		}
@@ -67,32 +130,67 @@ func (e *Extractor) ExtractMetadata(htmlContent string) (title, description stri
 		}
 	}
 	
-	extract(doc)
-	return title, description, nil
+	extract(d.Root)
+	return title, description
 }
 
-// ExtractEmails finds all email addresses in the content
-func (e *Extractor) ExtractEmails(content string) []string {
-	matches := e.emailRegex.FindAllString(content, -1)
-	return uniqueStrings(matches)
+// ExtractMetaRobots returns the lowercased directives of <meta name="robots">
+// and <meta name="googlebot"> tags, in document order
+func (e *Extractor) ExtractMetaRobots(htmlContent string) ([]string, error) {
+	doc, err := Parse(htmlContent, "")
+	if err != nil {
+		return nil, err
+	}
+	return doc.MetaRobots(), nil
 }
 
-// ExtractPhones finds all phone numbers in the content
-func (e *Extractor) ExtractPhones(content string) []string {
-	matches := e.phoneRegex.FindAllString(content, -1)
-	cleaned := make([]string, 0, len(matches))
+// MetaRobots returns the lowercased directives of the document's robots and
+// googlebot meta tags, in document order
+func (d *HTMLDocument) MetaRobots() []string {
+	var directives []string
+	var extract func(*html.Node)
+	extract = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			var name, content string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "name":
+					name = strings.ToLower(strings.TrimSpace(attr.Val))
+				case "content":
+					content = attr.Val
+				}
+			}
+			if name == "robots" || name == "googlebot" {
+				for _, d := range strings.Split(content, ",") {
+					if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+						directives = append(directives, d)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+
+	extract(d.Root)
+	return directives
+}
+
+// ExtractWhatsApps finds WhatsApp numbers from wa.me links and "WhatsApp:" labels
+func (e *Extractor) ExtractWhatsApps(content string) []string {
+	matches := e.whatsappRegex.FindAllStringSubmatch(content, -1)
+	numbers := make([]string, 0, len(matches))
 	for _, match := range matches {
-		// Clean and validate phone numbers
-		cleaned = append(cleaned, cleanPhoneNumber(match))
+		numbers = append(numbers, match[1])
 	}
-	return uniqueStrings(cleaned)
+	return uniquePhones(numbers)
 }
 
 // ExtractSocialHandles extracts social media handles
 func (e *Extractor) ExtractSocialHandles(content string) (twitter, linkedin []string) {
 	// Twitter/X handles
-	twitterRegex := regexp.MustCompile(`(?:twitter\.com|x\.com)/([a-zA-Z0-9_]+)`)
-	twitterMatches := twitterRegex.FindAllStringSubmatch(content, -1)
+	twitterMatches := e.twitterRegex.FindAllStringSubmatch(content, -1)
 	for _, match := range twitterMatches {
 		if len(match) > 1 {
 			twitter = append(twitter, "@"+match[1])
@@ -100,8 +198,7 @@ func (e *Extractor) ExtractSocialHandles(content string) (twitter, linkedin []st
 	}
 	
 	// LinkedIn profiles
-	linkedinRegex := regexp.MustCompile(`linkedin\.com/in/([a-zA-Z0-9-]+)`)
-	linkedinMatches := linkedinRegex.FindAllStringSubmatch(content, -1)
+	linkedinMatches := e.linkedinRegex.FindAllStringSubmatch(content, -1)
 	for _, match := range linkedinMatches {
 		if len(match) > 1 {
 			linkedin = append(linkedin, match[1])
@@ -111,30 +208,44 @@ func (e *Extractor) ExtractSocialHandles(content string) (twitter, linkedin []st
 	return uniqueStrings(twitter), uniqueStrings(linkedin)
 }
 
-// ExtractLinks extracts all links from HTML
-func (e *Extractor) ExtractLinks(htmlContent string, baseURL string) ([]Link, error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
+// ExtractLinks returns the links of an HTML document resolved as described in
+// RFC 3986 against the document base: pageURL, or the <base href> if the
+// document sets one. Each link is classified by scheme and, for web links,
+// by whether it stays on the page's host, a subdomain or leaves the site.
+func (e *Extractor) ExtractLinks(htmlContent string, pageURL string) ([]Link, error) {
+	doc, err := Parse(htmlContent, pageURL)
 	if err != nil {
 		return nil, err
 	}
-	
+	return doc.Links(), nil
+}
+
+// Links returns the document's links resolved against its base
+func (d *HTMLDocument) Links() []Link {
+	base := d.Base
 	var links []Link
 	var extract func(*html.Node)
 	extract = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			var href, text string
+		if n.Type == html.ElementNode && (n.Data == "a" || n.Data == "area") {
+			var href, text, rel string
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
-					href = attr.Val
+					href = cleanHref(attr.Val)
+				}
+				if attr.Key == "rel" {
+					rel = strings.Join(strings.Fields(strings.ToLower(attr.Val)), " ")
 				}
 			}
 			if n.FirstChild != nil {
 				text = extractText(n)
 			}
-			if href != "" {
+			if u, err := base.Parse(href); href != "" && err == nil {
 				links = append(links, Link{
-					URL:        resolveURL(baseURL, href),
+					URL:        u.String(),
 					AnchorText: strings.TrimSpace(text),
+					Rel:        rel,
+					Scheme:     strings.ToLower(u.Scheme),
+					Scope:      linkScope(base, u),
 				})
 			}
 		}
@@ -143,14 +254,96 @@ func (e *Extractor) ExtractLinks(htmlContent string, baseURL string) ([]Link, er
 		}
 	}
 	
-	extract(doc)
-	return links, nil
+	extract(d.Root)
+	return links
 }
 
 // Link represents an extracted hyperlink
 type Link struct {
 	URL        string
 	AnchorText string
+	Rel        string
+	Scheme     string // Lowercased, e.g. https, mailto, tel or javascript
+	Scope      string // models.LinkInternal, LinkSubdomain or LinkExternal; empty unless http(s)
+}
+
+// ExtractForms returns the forms of an HTML document with their action
+// resolved against pageURL and the initial values of their fields, hidden
+// inputs such as CSRF tokens included
+func (e *Extractor) ExtractForms(htmlContent string, pageURL string) ([]Form, error) {
+	doc, err := Parse(htmlContent, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Forms(), nil
+}
+
+// Forms returns the document's forms with their actions resolved against its
+// base
+func (d *HTMLDocument) Forms() []Form {
+	base := d.Base
+	var forms []Form
+	var form *Form
+	var extract func(*html.Node)
+	extract = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := make(map[string]string, len(n.Attr))
+			for _, attr := range n.Attr {
+				attrs[attr.Key] = attr.Val
+			}
+			switch {
+			case n.Data == "form":
+				f := Form{Action: base.String(), Method: strings.ToUpper(strings.TrimSpace(attrs["method"]))}
+				if action := cleanHref(attrs["action"]); action != "" {
+					if u, err := base.Parse(action); err == nil {
+						f.Action = u.String()
+					}
+				}
+				if f.Method == "" {
+					f.Method = "GET"
+				}
+				forms = append(forms, f)
+				form = &forms[len(forms)-1]
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					extract(c)
+				}
+				form = nil
+				return
+			case form != nil && (n.Data == "input" || n.Data == "textarea" || n.Data == "select") && attrs["name"] != "":
+				field := FormField{Name: attrs["name"], Type: strings.ToLower(attrs["type"]), Value: attrs["value"]}
+				if n.Data != "input" {
+					field.Type = n.Data
+				}
+				if field.Type == "" {
+					field.Type = "text"
+				}
+				if n.Data == "textarea" {
+					field.Value = extractText(n)
+				}
+				form.Fields = append(form.Fields, field)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+
+	extract(d.Root)
+	return forms
+}
+
+// Form is an HTML form with the fields it submits
+type Form struct {
+	Action string // Absolute URL the form submits to
+	Method string // Uppercased, GET when not set
+	Fields []FormField
+}
+
+// FormField is a named form control and its initial value
+type FormField struct {
+	Name  string
+	Type  string // Input type such as hidden, password or submit, or textarea or select
+	Value string
 }
 
 // Helper functions
@@ -167,6 +360,21 @@ func uniqueStrings(strings []string) []string {
 	return result
 }
 
+func uniquePhones(matches []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, match := range matches {
+		display := strings.Trim(match, " \t\r\n.-")
+		key := cleanPhoneNumber(display)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, display)
+	}
+	return result
+}
+
 func cleanPhoneNumber(phone string) string {
 	// Remove common separators but keep the number structure
 	cleaned := strings.ReplaceAll(phone, " ", "")
@@ -188,25 +396,50 @@ func extractText(n *html.Node) string {
 	return text
 }
 
-func resolveURL(base, href string) string {
-	if strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
-		return href
+// baseHref returns the href of the document's first <base> element that has one
+func baseHref(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "base" {
+		for _, attr := range n.Attr {
+			if attr.Key == "href" {
+				return attr.Val
+			}
+		}
 	}
-	if strings.HasPrefix(href, "//") {
-		return "https:" + href
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href := baseHref(c); href != "" {
+			return href
+		}
 	}
-	if strings.HasPrefix(href, "/") {
-		// Absolute path
-		if idx := strings.Index(base, "://"); idx > 0 {
-			if idx2 := strings.Index(base[idx+3:], "/"); idx2 > 0 {
-				return base[:idx+3+idx2] + href
-			}
-			return base + href
+	return ""
+}
+
+// cleanHref strips surrounding whitespace and the tabs and newlines browsers
+// ignore inside URLs
+func cleanHref(href string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
 		}
+		return r
+	}, strings.TrimSpace(href))
+}
+
+// linkScope classifies a web link relative to the document base: internal on
+// the same host (ignoring www.), subdomain within the same registrable domain,
+// external otherwise
+func linkScope(base, u *url.URL) string {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	from := strings.TrimPrefix(strings.ToLower(base.Hostname()), "www.")
+	to := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if from == to {
+		return models.LinkInternal
 	}
-	// Relative path
-	if !strings.HasSuffix(base, "/") {
-		base += "/"
+	fromDomain, err1 := publicsuffix.EffectiveTLDPlusOne(from)
+	toDomain, err2 := publicsuffix.EffectiveTLDPlusOne(to)
+	if err1 == nil && err2 == nil && fromDomain == toDomain {
+		return models.LinkSubdomain
 	}
-	return base + href
-}
\ No newline at end of file
+	return models.LinkExternal
+}
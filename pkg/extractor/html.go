@@ -0,0 +1,140 @@
+package extractor
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// HTMLDocument is an HTML page parsed once and shared by every extractor
+type HTMLDocument struct {
+	Root   *html.Node
+	Source string   // The markup as parsed, for extractors that scan it directly
+	URL    *url.URL // The page's own URL
+	Base   *url.URL // The URL links resolve against: the page URL or its <base href>
+}
+
+// Parse parses htmlContent, the document served at pageURL. pageURL may be
+// empty when links and forms are not needed.
+func Parse(htmlContent, pageURL string) (*HTMLDocument, error) {
+	root, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %q: %w", pageURL, err)
+	}
+
+	doc := &HTMLDocument{Root: root, Source: htmlContent, URL: u, Base: u}
+	if href := baseHref(root); href != "" {
+		if b, err := u.Parse(cleanHref(href)); err == nil {
+			doc.Base = b
+		}
+	}
+	return doc, nil
+}
+
+// ExtractOptions selects the optional parts of an Extraction
+type ExtractOptions struct {
+	Contacts    bool   // Look for emails, phone numbers and social profiles
+	PhoneRegion string // Region whose national phone numbers are recognized; empty accepts international ones only
+}
+
+// Extraction is everything extracted from one HTML page
+type Extraction struct {
+	Title       string
+	Description string
+	MetaRobots  []string
+	OnPage      *models.OnPage
+	Text        string // Main content, or the visible text when none is found
+	VisibleText string
+	Links       []Link
+	Contacts    *Contacts // Set when ExtractOptions.Contacts is
+
+	// StructuredData are the page's validated JSON-LD, Microdata and RDFa
+	// items, and SchemaTypes every schema.org type they use
+	StructuredData []models.StructuredData
+	SchemaTypes    []string
+}
+
+// Contacts are the contact details found on a page
+type Contacts struct {
+	Emails    []string // Addresses of EmailAddresses
+	Phones    []string // Display forms of PhoneNumbers
+	WhatsApps []string
+	XHandles  []string
+	LinkedIns []string
+
+	PhoneNumbers   []models.PhoneNumber
+	EmailAddresses []models.Email
+}
+
+// ExtractHTML parses htmlContent once and runs every extractor over it
+func (e *Extractor) ExtractHTML(htmlContent, pageURL string, opts ExtractOptions) (*Extraction, error) {
+	doc, err := Parse(htmlContent, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	return e.Extract(doc, opts), nil
+}
+
+// Extract runs every extractor over a parsed document
+func (e *Extractor) Extract(doc *HTMLDocument, opts ExtractOptions) *Extraction {
+	title, description := doc.Metadata()
+	x := &Extraction{
+		Title:       strings.TrimSpace(title),
+		Description: strings.TrimSpace(description),
+		MetaRobots:  doc.MetaRobots(),
+		OnPage:      doc.OnPage(),
+		VisibleText: doc.VisibleText(),
+		Links:       doc.Links(),
+	}
+	x.StructuredData = doc.StructuredData()
+	x.SchemaTypes = CheckStructuredData(x.StructuredData, x.VisibleText)
+	if opts.Contacts {
+		x.Contacts = e.ExtractContacts(doc.Source, x.VisibleText, opts.PhoneRegion)
+		x.Contacts.setPhones(mergePhones(x.Contacts.PhoneNumbers, TelPhones(x.Links, opts.PhoneRegion)))
+		x.Contacts.setEmails(mergeEmails(x.Contacts.EmailAddresses, MailtoEmails(x.Links)))
+	}
+
+	x.Text = x.VisibleText
+	if text, err := doc.MainText(); err == nil && text != "" {
+		x.Text = text
+	}
+	return x
+}
+
+// ExtractContacts finds contact details in content, such as the markup of a
+// page, looking for phone numbers only in its visible text where digits in
+// attributes and scripts cannot be mistaken for them. National numbers are
+// read in the numbering plan of region.
+func (e *Extractor) ExtractContacts(content, visible, region string) *Contacts {
+	contacts := &Contacts{WhatsApps: e.ExtractWhatsApps(content)}
+	contacts.setEmails(e.ExtractEmails(content))
+	contacts.setPhones(e.ExtractPhones(visible, region))
+	contacts.XHandles, contacts.LinkedIns = e.ExtractSocialHandles(content)
+	return contacts
+}
+
+// setPhones sets the phone numbers and their display forms
+func (c *Contacts) setPhones(numbers []models.PhoneNumber) {
+	c.PhoneNumbers = numbers
+	c.Phones = make([]string, len(numbers))
+	for i, n := range numbers {
+		c.Phones[i] = n.Display
+	}
+}
+
+// setEmails sets the email addresses and their plain forms
+func (c *Contacts) setEmails(emails []models.Email) {
+	c.EmailAddresses = emails
+	c.Emails = make([]string, len(emails))
+	for i, e := range emails {
+		c.Emails[i] = e.Address
+	}
+}
@@ -0,0 +1,141 @@
+package extractor
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// OnPage collects the document's SEO markup: canonical, alternate, pagination
+// and icon links, Open Graph, Twitter Card and viewport meta tags, the
+// language, the heading outline, images and the body word count
+func (d *HTMLDocument) OnPage() *models.OnPage {
+	p := &models.OnPage{}
+	var extract func(n *html.Node, inBody bool)
+	extract = func(n *html.Node, inBody bool) {
+		switch n.Type {
+		case html.TextNode:
+			if inBody {
+				p.WordCount += len(strings.Fields(n.Data))
+			}
+		case html.ElementNode:
+			if n.Namespace != "" {
+				// Elements of inline SVG and MathML share names with HTML ones
+				break
+			}
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			case "html":
+				p.Lang = strings.TrimSpace(attr(n, "lang"))
+			case "body":
+				inBody = true
+			case "link":
+				d.onPageLink(p, n)
+			case "meta":
+				onPageMeta(p, n)
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				text := strings.Join(strings.Fields(extractText(n)), " ")
+				p.Headings = append(p.Headings, models.Heading{Level: int(n.Data[1] - '0'), Text: text})
+			case "img":
+				p.Images++
+				if _, ok := attrOK(n, "alt"); !ok {
+					p.ImagesMissingAlt++
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c, inBody)
+		}
+	}
+
+	extract(d.Root, false)
+	return p
+}
+
+// onPageLink records a <link> element. The first canonical, prev, next and
+// icon link win; every alternate with an hreflang is kept.
+func (d *HTMLDocument) onPageLink(p *models.OnPage, n *html.Node) {
+	href := cleanHref(attr(n, "href"))
+	if href == "" {
+		return
+	}
+	u, err := d.Base.Parse(href)
+	if err != nil {
+		return
+	}
+	target := u.String()
+
+	for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+		switch rel {
+		case "canonical":
+			setFirst(&p.Canonical, target)
+		case "alternate":
+			if lang := strings.TrimSpace(attr(n, "hreflang")); lang != "" {
+				p.Hreflangs = append(p.Hreflangs, models.Hreflang{Lang: lang, URL: target})
+			}
+		case "prev", "previous":
+			setFirst(&p.Prev, target)
+		case "next":
+			setFirst(&p.Next, target)
+		case "icon":
+			setFirst(&p.Favicon, target)
+		}
+	}
+}
+
+// onPageMeta records the viewport and the Open Graph and Twitter Card tags of
+// a <meta> element. Sites mix up the name and property attributes for both,
+// so either is accepted.
+func onPageMeta(p *models.OnPage, n *html.Node) {
+	name := strings.ToLower(strings.TrimSpace(attr(n, "name")))
+	key := strings.ToLower(strings.TrimSpace(attr(n, "property")))
+	if key == "" {
+		key = name
+	}
+	content := strings.TrimSpace(attr(n, "content"))
+
+	switch {
+	case name == "viewport":
+		setFirst(&p.Viewport, content)
+	case strings.HasPrefix(key, "og:"):
+		if p.OpenGraph == nil {
+			p.OpenGraph = make(map[string]string)
+		}
+		if _, ok := p.OpenGraph[key]; !ok {
+			p.OpenGraph[key] = content
+		}
+	case strings.HasPrefix(key, "twitter:"):
+		if p.TwitterCard == nil {
+			p.TwitterCard = make(map[string]string)
+		}
+		if _, ok := p.TwitterCard[key]; !ok {
+			p.TwitterCard[key] = content
+		}
+	}
+}
+
+// setFirst sets *field to value unless it already holds one
+func setFirst(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// attr returns the value of an element's attribute, or an empty string
+func attr(n *html.Node, key string) string {
+	val, _ := attrOK(n, key)
+	return val
+}
+
+// attrOK returns the value of an element's attribute and whether it is set
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
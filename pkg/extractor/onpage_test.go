@@ -0,0 +1,76 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+func TestOnPage(t *testing.T) {
+	doc, err := Parse(`<!DOCTYPE html>
+<html lang=" en-GB ">
+<head>
+	<base href="https://cdn.example.com/en/">
+	<title>Guide to anvils</title>
+	<link rel="canonical" href="/guide">
+	<link rel="canonical" href="/other">
+	<link rel="alternate" hreflang="de" href="https://example.de/guide">
+	<link rel="alternate" hreflang="x-default" href="guide">
+	<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+	<link rel="Previous" href="page/1">
+	<link rel="next" href="page/3">
+	<link rel="shortcut icon" href="/favicon.ico">
+	<link rel="icon" href="/icon.png">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta property="og:title" content=" Anvils ">
+	<meta property="og:title" content="Ignored">
+	<meta name="og:image" content="https://example.com/anvil.jpg">
+	<meta property="twitter:card" content="summary">
+	<meta name="twitter:site" content="@example">
+	<meta name="description" content="Not on-page markup">
+	<script>var words = "not counted";</script>
+</head>
+<body>
+	<h1>Anvils <em>explained</em></h1>
+	<p>Three more words.</p>
+	<h3>
+		Choosing one
+	</h3>
+	<img src="a.jpg" alt="An anvil">
+	<img src="spacer.gif" alt="">
+	<img src="b.jpg">
+	<svg><image href="c.png"/></svg>
+	<noscript><img src="pixel.gif"> enable scripts</noscript>
+	<template><h2>Hidden</h2></template>
+</body>
+</html>`, "https://example.com/en/guide")
+	require.NoError(t, err)
+
+	assert.Equal(t, &models.OnPage{
+		Canonical: "https://cdn.example.com/guide",
+		Hreflangs: []models.Hreflang{
+			{Lang: "de", URL: "https://example.de/guide"},
+			{Lang: "x-default", URL: "https://cdn.example.com/en/guide"},
+		},
+		OpenGraph:        map[string]string{"og:title": "Anvils", "og:image": "https://example.com/anvil.jpg"},
+		TwitterCard:      map[string]string{"twitter:card": "summary", "twitter:site": "@example"},
+		Viewport:         "width=device-width, initial-scale=1",
+		Lang:             "en-GB",
+		Headings:         []models.Heading{{Level: 1, Text: "Anvils explained"}, {Level: 3, Text: "Choosing one"}},
+		Images:           3,
+		ImagesMissingAlt: 1,
+		WordCount:        7,
+		Prev:             "https://cdn.example.com/en/page/1",
+		Next:             "https://cdn.example.com/en/page/3",
+		Favicon:          "https://cdn.example.com/favicon.ico",
+	}, doc.OnPage())
+}
+
+func TestOnPageEmpty(t *testing.T) {
+	doc, err := Parse(`<p>Just text</p><link rel="canonical" href="">`, "https://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, &models.OnPage{WordCount: 2}, doc.OnPage())
+}
@@ -0,0 +1,301 @@
+package extractor
+
+import (
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// numberingPlan describes how a region writes and validates phone numbers
+type numberingPlan struct {
+	region  string         // ISO 3166 region code
+	code    string         // Country calling code
+	trunk   string         // National prefix dialled before the number; "0" is required when it applies
+	intl    string         // Prefix dialled before a calling code
+	pattern *regexp.Regexp // Valid national significant numbers
+}
+
+// numberingPlans are the bundled numbering plans. Regions sharing a calling
+// code are listed most specific first, as the first plan whose pattern
+// matches a number decides its region.
+var numberingPlans = []numberingPlan{
+	plan("CA", "1", "1", "011", `(?:204|226|236|249|250|263|289|306|343|354|365|367|368|382|387|403|416|418|428|431|437|438|450|460|468|474|506|514|519|548|579|581|584|587|604|613|639|647|672|683|705|709|742|753|778|780|782|807|819|825|867|873|879|902|905|942)[2-9](?:[02-9]\d|1[02-9])\d{4}`),
+	plan("US", "1", "1", "011", `[2-9](?:[02-9]\d|1[02-9])[2-9](?:[02-9]\d|1[02-9])\d{4}`),
+	plan("KZ", "7", "8", "810", `[67]\d{9}`),
+	plan("RU", "7", "8", "810", `[3489]\d{9}`),
+	plan("ZA", "27", "0", "00", `[1-8]\d{8}`),
+	plan("NL", "31", "0", "00", `[1-9]\d{8}`),
+	plan("BE", "32", "0", "00", `4\d{8}|[1-9]\d{7}`),
+	plan("FR", "33", "0", "00", `[1-9]\d{8}`),
+	plan("ES", "34", "", "00", `[5-9]\d{8}`),
+	plan("IT", "39", "", "00", `0\d{5,10}|3\d{8,9}`),
+	plan("CH", "41", "0", "00", `[2-9]\d{8}`),
+	plan("AT", "43", "0", "00", `[1-9]\d{3,12}`),
+	plan("GB", "44", "0", "00", `[1-3]\d{8,9}|7\d{9}|8\d{8,9}|9\d{9}`),
+	plan("DK", "45", "", "00", `[2-9]\d{7}`),
+	plan("SE", "46", "0", "00", `[1-9]\d{6,9}`),
+	plan("NO", "47", "", "00", `[2-9]\d{7}`),
+	plan("PL", "48", "", "00", `[1-9]\d{8}`),
+	plan("DE", "49", "0", "00", `1[5-7]\d{8,9}|[2-9]\d{5,11}|1[0-48-9]\d{4,10}`),
+	plan("MX", "52", "", "00", `[1-9]\d{9}`),
+	plan("BR", "55", "0", "00", `[1-9]{2}(?:9\d{8}|[2-5]\d{7})`),
+	plan("AU", "61", "0", "0011", `[2-478]\d{8}`),
+	plan("NZ", "64", "0", "00", `[2-9]\d{7,9}`),
+	plan("SG", "65", "", "000", `[3689]\d{7}`),
+	plan("JP", "81", "0", "010", `[1-9]\d{8,9}`),
+	plan("KR", "82", "0", "001", `[1-9]\d{7,9}`),
+	plan("CN", "86", "0", "00", `1[3-9]\d{9}|[2-9]\d{8,10}`),
+	plan("IN", "91", "0", "00", `[1-9]\d{9}`),
+	plan("PT", "351", "", "00", `[29]\d{8}`),
+	plan("IE", "353", "0", "00", `[1-9]\d{6,9}`),
+	plan("FI", "358", "0", "00", `[1-9]\d{4,11}`),
+	plan("HK", "852", "", "001", `[2-9]\d{7}`),
+	plan("AE", "971", "0", "00", `5\d{8}|[2-4679]\d{7}`),
+	plan("IL", "972", "0", "00", `[57]\d{8}|[2-489]\d{7}`),
+}
+
+func plan(region, code, trunk, intl, pattern string) numberingPlan {
+	return numberingPlan{region, code, trunk, intl, regexp.MustCompile(`^(?:` + pattern + `)$`)}
+}
+
+var (
+	phoneDate  = regexp.MustCompile(`^(?:\d{1,2}[./-]\d{1,2}[./-]\d{2,4}|\d{4}[./-]\d{1,2}[./-]\d{1,2})$`)
+	phoneLabel = regexp.MustCompile(`(?i)(?:tel|phone|fon|call|mobile|cell|fax|whatsapp|☎|📞)[^\d]{0,12}$`)
+)
+
+// phoneCurrencies are the symbols that mark a number as a price
+const phoneCurrencies = "$€£¥₹"
+
+// ParsePhoneNumber parses raw, a phone number written in international form or
+// in the national form of region, and reports whether it is valid in the
+// bundled numbering plans. Numbers in international form with a calling code
+// the plans do not cover are accepted on their length alone, without a
+// region. An empty region accepts only international numbers.
+func ParsePhoneNumber(raw, region string) (models.PhoneNumber, bool) {
+	display := strings.Trim(raw, " \t\r\n.-/")
+	number := models.PhoneNumber{Display: display}
+	// "+44 (0)20" writes the trunk prefix that international callers skip
+	digits := phoneDigits(strings.Replace(display, "(0)", "", 1))
+	if len(digits) < 7 || len(digits) > 17 {
+		return number, false
+	}
+
+	home := planForRegion(strings.ToUpper(strings.TrimSpace(region)))
+	international := strings.HasPrefix(display, "+")
+	if !international {
+		for _, prefix := range []string{home.intl, "00"} {
+			if prefix != "" && strings.HasPrefix(digits, prefix) {
+				digits, international = digits[len(prefix):], true
+				break
+			}
+		}
+	}
+
+	var code, nsn string
+	switch {
+	case international:
+		for n := 1; n <= 3 && n < len(digits); n++ {
+			if planForCode(digits[:n]) != nil {
+				code, nsn = digits[:n], digits[n:]
+				break
+			}
+		}
+		if code == "" {
+			// A calling code the plans do not cover: E.164 still bounds the length
+			if len(digits) < 8 || len(digits) > 15 {
+				return number, false
+			}
+			number.E164 = "+" + digits
+			return number, true
+		}
+	case home.region != "":
+		code, nsn = home.code, digits
+		if home.trunk != "" && strings.HasPrefix(nsn, home.trunk) {
+			nsn = nsn[len(home.trunk):]
+		} else if home.trunk == "0" {
+			return number, false
+		}
+	default:
+		return number, false
+	}
+
+	p := matchPlan(code, nsn)
+	if p == nil && international && strings.HasPrefix(nsn, "0") {
+		// Sites often keep the trunk prefix after the calling code
+		nsn = nsn[1:]
+		p = matchPlan(code, nsn)
+	}
+	if p == nil {
+		return number, false
+	}
+	number.E164 = "+" + code + nsn
+	number.Region = p.region
+	return number, true
+}
+
+// planForRegion returns the numbering plan of region, or an empty plan
+func planForRegion(region string) numberingPlan {
+	for _, p := range numberingPlans {
+		if p.region == region {
+			return p
+		}
+	}
+	return numberingPlan{}
+}
+
+// planForCode returns the first numbering plan of a calling code, or nil
+func planForCode(code string) *numberingPlan {
+	for i := range numberingPlans {
+		if numberingPlans[i].code == code {
+			return &numberingPlans[i]
+		}
+	}
+	return nil
+}
+
+// matchPlan returns the plan of the region that nsn is valid in, or nil
+func matchPlan(code, nsn string) *numberingPlan {
+	for i, p := range numberingPlans {
+		if p.code == code && p.pattern.MatchString(nsn) {
+			return &numberingPlans[i]
+		}
+	}
+	return nil
+}
+
+// RegionForHost returns the region of a host's country-code top-level
+// domain when the bundled numbering plans cover it, or an empty string
+func RegionForHost(host string) string {
+	tld := strings.ToUpper(host[strings.LastIndex(host, ".")+1:])
+	if tld == "UK" {
+		tld = "GB"
+	}
+	if len(tld) != 2 || planForRegion(tld).region == "" {
+		return ""
+	}
+	return tld
+}
+
+// ExtractPhones finds the valid phone numbers in content, parsing national
+// numbers in the numbering plan of region. Numbers keep their display form
+// and are reported once per E.164 number. Confidence rises for numbers in
+// international form, with separators, or after a label such as "Tel:";
+// dates and prices are skipped.
+func (e *Extractor) ExtractPhones(content, region string) []models.PhoneNumber {
+	var numbers []models.PhoneNumber
+	for _, loc := range e.phoneRegex.FindAllStringIndex(content, -1) {
+		raw := content[loc[0]:loc[1]]
+		before := content[max(0, loc[0]-32):loc[0]]
+		if phoneDate.MatchString(raw) || isPrice(before) {
+			continue
+		}
+		number, ok := ParsePhoneNumber(raw, region)
+		if !ok {
+			continue
+		}
+
+		score := 50
+		if number.Region == "" {
+			score -= 20
+		}
+		if strings.HasPrefix(number.Display, "+") || strings.HasPrefix(number.Display, "00") {
+			score += 20
+		}
+		if strings.ContainsAny(number.Display, " .-/()") {
+			score += 10
+		}
+		if phoneLabel.MatchString(before) {
+			score += 20
+		}
+		number.Confidence = float64(score) / 100
+		numbers = addPhone(numbers, number)
+	}
+	return numbers
+}
+
+// TelPhones returns the valid phone numbers of tel: links, which sites add
+// precisely so the number can be dialled and so are trusted more than text
+func TelPhones(links []Link, region string) []models.PhoneNumber {
+	var numbers []models.PhoneNumber
+	for _, link := range links {
+		if link.Scheme != "tel" {
+			continue
+		}
+		u, err := url.Parse(link.URL)
+		if err != nil {
+			continue
+		}
+		raw := u.Opaque
+		if raw == "" {
+			raw = strings.TrimPrefix(u.Path, "//")
+		}
+		// Parameters such as ;ext= and ;phone-context= follow the number
+		raw, _, _ = strings.Cut(raw, ";")
+		if unescaped, err := url.PathUnescape(raw); err == nil {
+			raw = unescaped
+		}
+		number, ok := ParsePhoneNumber(raw, region)
+		if !ok {
+			continue
+		}
+		number.Confidence = 0.9
+		if number.Region == "" {
+			number.Confidence = 0.7
+		}
+		numbers = addPhone(numbers, number)
+	}
+	return numbers
+}
+
+// addPhone adds number to numbers unless its E.164 form is already there,
+// in which case the more confident sighting's confidence is kept
+func addPhone(numbers []models.PhoneNumber, number models.PhoneNumber) []models.PhoneNumber {
+	for i := range numbers {
+		if numbers[i].E164 == number.E164 {
+			numbers[i].Confidence = math.Max(numbers[i].Confidence, number.Confidence)
+			return numbers
+		}
+	}
+	return append(numbers, number)
+}
+
+// mergePhones adds the numbers of tel: links to those found in text. A
+// number found in both is the most trustworthy of all.
+func mergePhones(text, tels []models.PhoneNumber) []models.PhoneNumber {
+	for _, tel := range tels {
+		found := false
+		for i := range text {
+			if text[i].E164 == tel.E164 {
+				confidence := math.Max(text[i].Confidence, tel.Confidence) + 0.1
+				text[i].Confidence = math.Min(1, math.Round(confidence*100)/100)
+				found = true
+				break
+			}
+		}
+		if !found {
+			text = append(text, tel)
+		}
+	}
+	return text
+}
+
+// isPrice reports whether the text before a number ends in a currency symbol
+func isPrice(before string) bool {
+	r, _ := utf8.DecodeLastRuneInString(strings.TrimRight(before, " "))
+	return strings.ContainsRune(phoneCurrencies, r)
+}
+
+// phoneDigits returns the digits of s
+func phoneDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
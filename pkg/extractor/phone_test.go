@@ -0,0 +1,85 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+func TestParsePhoneNumber(t *testing.T) {
+	tests := []struct {
+		raw, region string
+		e164        string // Empty when the number is rejected
+		want        string // Region of the parsed number
+	}{
+		{"+44 20 7946 0958", "", "+442079460958", "GB"},
+		{"+44 (0)20 7946 0958", "", "+442079460958", "GB"},
+		{"020 7946 0958", "GB", "+442079460958", "GB"},
+		{"030 123456", "DE", "+4930123456", "DE"},
+		{"(212) 555-0123", "US", "+12125550123", "US"},
+		{"+1 416 555 0199", "US", "+14165550199", "CA"},
+		{"0049 30 123456", "US", "+4930123456", "DE"},
+		{"+49 030 123456", "", "+4930123456", "DE"},
+		{"+999 1234 5678", "", "+99912345678", ""},
+
+		{"12345", "DE", "", ""},
+		{"020 7946 0958", "", "", ""},
+		{"7946 0958", "GB", "", ""},
+		{"+1 123 456 7890", "", "", ""},
+		{"+999 123", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw+" "+tt.region, func(t *testing.T) {
+			number, ok := ParsePhoneNumber(tt.raw, tt.region)
+			assert.Equal(t, tt.e164 != "", ok)
+			assert.Equal(t, tt.e164, number.E164)
+			assert.Equal(t, tt.want, number.Region)
+		})
+	}
+}
+
+func TestRegionForHost(t *testing.T) {
+	assert.Equal(t, "GB", RegionForHost("www.example.co.uk"))
+	assert.Equal(t, "DE", RegionForHost("example.de"))
+	assert.Equal(t, "", RegionForHost("example.com"))
+	assert.Equal(t, "", RegionForHost("example.zz"))
+}
+
+func TestExtractPhones(t *testing.T) {
+	e := New()
+	content := `Tel: +49 30 1234567. Call 030 1234567 again. Founded 12.05.2024, price € 030 7654321`
+	phones := e.ExtractPhones(content, "DE")
+	assert.Equal(t, []models.PhoneNumber{
+		{Display: "+49 30 1234567", E164: "+49301234567", Region: "DE", Confidence: 1},
+	}, phones, "the national spelling of the same number is reported once")
+}
+
+func TestTelPhones(t *testing.T) {
+	links := []Link{
+		{URL: "tel:+493012345678", Scheme: "tel"},
+		{URL: "tel:030-1234567;ext=12", Scheme: "tel"},
+		{URL: "tel:%2B999%2012345678", Scheme: "tel"},
+		{URL: "tel:12", Scheme: "tel"},
+		{URL: "mailto:+493012345678@example.com", Scheme: "mailto"},
+	}
+	assert.Equal(t, []models.PhoneNumber{
+		{Display: "+493012345678", E164: "+493012345678", Region: "DE", Confidence: 0.9},
+		{Display: "030-1234567", E164: "+49301234567", Region: "DE", Confidence: 0.9},
+		{Display: "+999 12345678", E164: "+99912345678", Confidence: 0.7},
+	}, TelPhones(links, "DE"))
+}
+
+func TestMergePhones(t *testing.T) {
+	text := []models.PhoneNumber{{Display: "030 1234567", E164: "+49301234567", Region: "DE", Confidence: 0.8}}
+	tels := []models.PhoneNumber{
+		{Display: "+49301234567", E164: "+49301234567", Region: "DE", Confidence: 0.9},
+		{Display: "+493012345678", E164: "+493012345678", Region: "DE", Confidence: 0.9},
+	}
+	assert.Equal(t, []models.PhoneNumber{
+		{Display: "030 1234567", E164: "+49301234567", Region: "DE", Confidence: 1},
+		{Display: "+493012345678", E164: "+493012345678", Region: "DE", Confidence: 0.9},
+	}, mergePhones(text, tels))
+}
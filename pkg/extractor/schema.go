@@ -0,0 +1,351 @@
+package extractor
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// schemaTypes is the bundled schema.org vocabulary: the types used for rich
+// results and the ones they commonly nest, each with its parent types and the
+// properties it adds to them
+var schemaTypes = map[string]struct{ parents, properties string }{
+	"Thing":                     {"", "additionalType alternateName description disambiguatingDescription identifier image mainEntityOfPage name potentialAction sameAs subjectOf url"},
+	"Intangible":                {"Thing", ""},
+	"StructuredValue":           {"Intangible", ""},
+	"CreativeWork":              {"Thing", "about abstract accessMode accessModeSufficient accessibilityAPI accessibilityControl accessibilityFeature accessibilityHazard accessibilitySummary accountablePerson acquireLicensePage aggregateRating alternativeHeadline archivedAt assesses associatedMedia audience audio author award awards character citation comment commentCount conditionsOfAccess contentLocation contentRating contentReferenceTime contributor copyrightHolder copyrightNotice copyrightYear correction countryOfOrigin creativeWorkStatus creator creditText dateCreated dateModified datePublished digitalSourceType discussionUrl editEIDR editor educationalAlignment educationalLevel educationalUse encoding encodingFormat encodings exampleOfWork expires fileFormat funder funding genre hasPart headline inLanguage interactionStatistic interactivityType interpretedAsClaim isAccessibleForFree isBasedOn isBasedOnUrl isFamilyFriendly isPartOf keywords learningResourceType license locationCreated mainEntity maintainer material materialExtent mentions offers pattern position producer provider publication publisher publisherImprint publishingPrinciples recordedAt releasedEvent review reviews schemaVersion sdDatePublished sdLicense sdPublisher size sourceOrganization spatial spatialCoverage sponsor teaches temporal temporalCoverage text thumbnail thumbnailUrl timeRequired translationOfWork translator typicalAgeRange usageInfo version video workExample workTranslation"},
+	"Article":                   {"CreativeWork", "articleBody articleSection backstory pageEnd pageStart pagination speakable wordCount"},
+	"NewsArticle":               {"Article", "dateline printColumn printEdition printPage printSection"},
+	"SocialMediaPosting":        {"Article", "sharedContent"},
+	"BlogPosting":               {"SocialMediaPosting", ""},
+	"Report":                    {"Article", "reportNumber"},
+	"ScholarlyArticle":          {"Article", ""},
+	"TechArticle":               {"Article", "dependencies proficiencyLevel"},
+	"WebPage":                   {"CreativeWork", "breadcrumb lastReviewed mainContentOfPage primaryImageOfPage relatedLink reviewedBy significantLink significantLinks speakable specialty"},
+	"AboutPage":                 {"WebPage", ""},
+	"CheckoutPage":              {"WebPage", ""},
+	"CollectionPage":            {"WebPage", ""},
+	"ContactPage":               {"WebPage", ""},
+	"FAQPage":                   {"WebPage", ""},
+	"ItemPage":                  {"WebPage", ""},
+	"ProfilePage":               {"WebPage", ""},
+	"QAPage":                    {"WebPage", ""},
+	"SearchResultsPage":         {"WebPage", ""},
+	"WebSite":                   {"CreativeWork", "issn"},
+	"WebPageElement":            {"CreativeWork", "cssSelector xpath"},
+	"SiteNavigationElement":     {"WebPageElement", ""},
+	"WPHeader":                  {"WebPageElement", ""},
+	"WPFooter":                  {"WebPageElement", ""},
+	"Comment":                   {"CreativeWork", "downvoteCount parentItem sharedContent upvoteCount"},
+	"Question":                  {"Comment", "acceptedAnswer answerCount eduQuestionType suggestedAnswer"},
+	"Answer":                    {"Comment", "answerExplanation parentItem"},
+	"Review":                    {"CreativeWork", "associatedClaimReview associatedMediaReview associatedReview itemReviewed negativeNotes positiveNotes reviewAspect reviewBody reviewRating"},
+	"MediaObject":               {"CreativeWork", "associatedArticle bitrate contentSize contentUrl duration embedUrl encodesCreativeWork encodingFormat endTime height ineligibleRegion interpretedAsClaim playerType productionCompany regionsAllowed requiresSubscription sha256 startTime uploadDate width"},
+	"ImageObject":               {"MediaObject", "caption embeddedTextCaption exifData representativeOfPage"},
+	"VideoObject":               {"MediaObject", "actor actors caption director directors embeddedTextCaption musicBy transcript videoFrameSize videoQuality"},
+	"AudioObject":               {"MediaObject", "caption embeddedTextCaption transcript"},
+	"HowTo":                     {"CreativeWork", "estimatedCost performTime prepTime step steps supply tool totalTime yield"},
+	"HowToStep":                 {"CreativeWork ItemList ListItem", ""},
+	"HowToSection":              {"CreativeWork ItemList ListItem", "steps"},
+	"Recipe":                    {"HowTo", "cookTime cookingMethod ingredients nutrition recipeCategory recipeCuisine recipeIngredient recipeInstructions recipeYield suitableForDiet"},
+	"SoftwareApplication":       {"CreativeWork", "applicationCategory applicationSubCategory applicationSuite availableOnDevice countriesNotSupported countriesSupported device downloadUrl featureList fileSize installUrl memoryRequirements operatingSystem permissions processorRequirements releaseNotes requirements screenshot softwareAddOn softwareHelp softwareRequirements softwareVersion storageRequirements supportingData"},
+	"WebApplication":            {"SoftwareApplication", "browserRequirements"},
+	"MobileApplication":         {"SoftwareApplication", "carrierRequirements"},
+	"Course":                    {"CreativeWork", "availableLanguage courseCode coursePrerequisites educationalCredentialAwarded financialAidEligible hasCourseInstance numberOfCredits occupationalCredentialAwarded syllabusSections totalHistoricalEnrollment"},
+	"Book":                      {"CreativeWork", "abridged bookEdition bookFormat illustrator isbn numberOfPages"},
+	"Organization":              {"Thing", "acceptedPaymentMethod actionableFeedbackPolicy address agentInteractionStatistic aggregateRating alumni areaServed award awards brand contactPoint contactPoints correctionsPolicy department dissolutionDate diversityPolicy diversityStaffingReport duns email employee employees ethicsPolicy event events faxNumber founder founders foundingDate foundingLocation funder funding globalLocationNumber hasCredential hasMerchantReturnPolicy hasOfferCatalog hasPOS interactionStatistic isicV4 iso6523Code keywords knowsAbout knowsLanguage legalName leiCode location logo makesOffer member memberOf members naics nonprofitStatus numberOfEmployees ownershipFundingInfo owns parentOrganization publishingPrinciples review reviews seeks serviceArea slogan sponsor subOrganization taxID telephone unnamedSourcesPolicy vatID"},
+	"Corporation":               {"Organization", "tickerSymbol"},
+	"EducationalOrganization":   {"Organization", "alumni"},
+	"NewsMediaOrganization":     {"Organization", "actionableFeedbackPolicy correctionsPolicy diversityPolicy diversityStaffingReport ethicsPolicy masthead missionCoveragePrioritiesPolicy noBylinesPolicy ownershipFundingInfo unnamedSourcesPolicy verificationFactCheckingPolicy"},
+	"Place":                     {"Thing", "additionalProperty address aggregateRating amenityFeature branchCode containedIn containedInPlace containsPlace event events faxNumber geo geoContains geoCoveredBy geoCovers geoCrosses geoDisjoint geoEquals geoIntersects geoOverlaps geoTouches geoWithin globalLocationNumber hasDriveThroughService hasMap isAccessibleForFree isicV4 keywords latitude logo longitude map maps maximumAttendeeCapacity openingHoursSpecification photo photos publicAccess review reviews slogan smokingAllowed specialOpeningHoursSpecification telephone tourBookingPage"},
+	"AdministrativeArea":        {"Place", ""},
+	"Country":                   {"AdministrativeArea", ""},
+	"State":                     {"AdministrativeArea", ""},
+	"City":                      {"AdministrativeArea", ""},
+	"LocalBusiness":             {"Organization Place", "currenciesAccepted openingHours paymentAccepted priceRange"},
+	"Store":                     {"LocalBusiness", ""},
+	"ProfessionalService":       {"LocalBusiness", ""},
+	"FoodEstablishment":         {"LocalBusiness", "acceptsReservations hasMenu menu servesCuisine starRating"},
+	"Restaurant":                {"FoodEstablishment", ""},
+	"LodgingBusiness":           {"LocalBusiness", "amenityFeature audience availableLanguage checkinTime checkoutTime numberOfRooms petsAllowed starRating"},
+	"Hotel":                     {"LodgingBusiness", ""},
+	"Person":                    {"Thing", "additionalName address affiliation agentInteractionStatistic alumniOf award awards birthDate birthPlace brand callSign children colleague colleagues contactPoint contactPoints deathDate deathPlace duns email familyName faxNumber follows funder funding gender givenName globalLocationNumber hasCredential hasOccupation hasOfferCatalog hasPOS height homeLocation honorificPrefix honorificSuffix interactionStatistic isicV4 jobTitle knows knowsAbout knowsLanguage makesOffer memberOf naics nationality netWorth owns parent parents performerIn publishingPrinciples relatedTo seeks sibling siblings skills sponsor spouse taxID telephone vatID weight workLocation worksFor"},
+	"Product":                   {"Thing", "additionalProperty aggregateRating asin audience award awards brand category color colorSwatch countryOfAssembly countryOfLastProcessing countryOfOrigin depth funding gtin gtin12 gtin13 gtin14 gtin8 hasAdultConsideration hasCertification hasEnergyConsumptionDetails hasMeasurement hasMerchantReturnPolicy height inProductGroupWithID isAccessoryOrSparePartFor isConsumableFor isFamilyFriendly isRelatedTo isSimilarTo isVariantOf itemCondition keywords logo manufacturer material mobileUrl model mpn negativeNotes nsn offers pattern positiveNotes productID productionDate purchaseDate releaseDate review reviews size sku slogan weight width"},
+	"ProductGroup":              {"Product", "hasVariant productGroupID variesBy"},
+	"Offer":                     {"Intangible", "acceptedPaymentMethod addOn advanceBookingRequirement aggregateRating areaServed asin availability availabilityEnds availabilityStarts availableAtOrFrom availableDeliveryMethod businessFunction category checkoutPageURLTemplate deliveryLeadTime eligibleCustomerType eligibleDuration eligibleQuantity eligibleRegion eligibleTransactionVolume gtin gtin12 gtin13 gtin14 gtin8 hasAdultConsideration hasMeasurement hasMerchantReturnPolicy includesObject ineligibleRegion inventoryLevel isFamilyFriendly itemCondition itemOffered leaseLength mobileUrl mpn offeredBy price priceCurrency priceSpecification priceValidUntil review reviews seller serialNumber shippingDetails sku validFrom validThrough warranty"},
+	"AggregateOffer":            {"Offer", "highPrice lowPrice offerCount offers"},
+	"Rating":                    {"Intangible", "author bestRating ratingExplanation ratingValue reviewAspect worstRating"},
+	"AggregateRating":           {"Rating", "itemReviewed ratingCount reviewCount"},
+	"Brand":                     {"Intangible", "aggregateRating logo review slogan"},
+	"Service":                   {"Intangible", "aggregateRating areaServed audience availableChannel award brand broker category hasCertification hasOfferCatalog hoursAvailable isRelatedTo isSimilarTo logo offers produces provider providerMobility review serviceArea serviceAudience serviceOutput serviceType slogan termsOfService"},
+	"JobPosting":                {"Intangible", "applicantLocationRequirements applicationContact baseSalary benefits datePosted directApply educationRequirements eligibilityToWorkRequirement employerOverview employmentType employmentUnit estimatedSalary experienceInPlaceOfEducation experienceRequirements hiringOrganization incentiveCompensation incentives industry jobBenefits jobImmediateStart jobLocation jobLocationType jobStartDate jobTitle occupationalCategory physicalRequirement qualifications relevantOccupation responsibilities salaryCurrency securityClearanceRequirement sensoryRequirement skills specialCommitments title totalJobOpenings validThrough workHours"},
+	"ItemList":                  {"Intangible", "itemListElement itemListOrder numberOfItems"},
+	"BreadcrumbList":            {"ItemList", ""},
+	"ListItem":                  {"Intangible", "item nextItem position previousItem"},
+	"MerchantReturnPolicy":      {"Intangible", "additionalProperty applicableCountry customerRemorseReturnFees customerRemorseReturnLabelSource customerRemorseReturnShippingFeesAmount inStoreReturnsOffered itemCondition itemDefectReturnFees itemDefectReturnLabelSource itemDefectReturnShippingFeesAmount merchantReturnDays merchantReturnLink refundType restockingFee returnFees returnLabelSource returnMethod returnPolicyCategory returnPolicyCountry returnPolicySeasonalOverride returnShippingFeesAmount validForMemberTier"},
+	"SpeakableSpecification":    {"Intangible", "cssSelector xpath"},
+	"EntryPoint":                {"Intangible", "actionApplication actionPlatform application contentType encodingType httpMethod urlTemplate"},
+	"ContactPoint":              {"StructuredValue", "areaServed availableLanguage contactOption contactType email faxNumber hoursAvailable productSupported serviceArea telephone"},
+	"PostalAddress":             {"ContactPoint", "addressCountry addressLocality addressRegion postOfficeBoxNumber postalCode streetAddress"},
+	"GeoCoordinates":            {"StructuredValue", "address addressCountry elevation latitude longitude postalCode"},
+	"OpeningHoursSpecification": {"StructuredValue", "closes dayOfWeek opens validFrom validThrough"},
+	"PriceSpecification":        {"StructuredValue", "eligibleQuantity eligibleTransactionVolume maxPrice membershipPointsEarned minPrice price priceCurrency validForMemberTier validFrom validThrough valueAddedTaxIncluded"},
+	"UnitPriceSpecification":    {"PriceSpecification", "billingDuration billingIncrement billingStart priceComponentType priceType referenceQuantity unitCode unitText"},
+	"MonetaryAmount":            {"StructuredValue", "currency maxValue minValue validFrom validThrough value"},
+	"QuantitativeValue":         {"StructuredValue", "additionalProperty maxValue minValue unitCode unitText value valueReference"},
+	"PropertyValue":             {"StructuredValue", "maxValue measurementMethod measurementTechnique minValue propertyID unitCode unitText value valueReference"},
+	"NutritionInformation":      {"StructuredValue", "calories carbohydrateContent cholesterolContent fatContent fiberContent proteinContent saturatedFatContent servingSize sodiumContent sugarContent transFatContent unsaturatedFatContent"},
+	"OfferShippingDetails":      {"StructuredValue", "deliveryTime depth doesNotShip height shippingDestination shippingLabel shippingOrigin shippingRate shippingSettingsLink transitTimeLabel validForMemberTier weight width"},
+	"DefinedRegion":             {"StructuredValue", "addressCountry addressRegion postalCode postalCodePrefix postalCodeRange"},
+	"ShippingDeliveryTime":      {"StructuredValue", "businessDays cutoffTime handlingTime transitTime"},
+	"Event":                     {"Thing", "about actor aggregateRating attendee attendees audience composer contributor director doorTime duration endDate eventAttendanceMode eventSchedule eventStatus funder funding inLanguage isAccessibleForFree keywords location maximumAttendeeCapacity maximumPhysicalAttendeeCapacity maximumVirtualAttendeeCapacity offers organizer performer performers previousStartDate recordedIn remainingAttendeeCapacity review sponsor startDate subEvent subEvents superEvent translator typicalAgeRange workFeatured workPerformed"},
+	"Action":                    {"Thing", "actionProcess actionStatus agent endTime error instrument location object participant provider result startTime target"},
+	// query-input is Google's annotation for sitelinks search boxes
+	"SearchAction": {"Action", "query query-input"},
+}
+
+// richResults are Google's required and recommended rich result properties.
+// A type uses the rules of its nearest type that has any, and "a|b" requires
+// one of the alternatives.
+var richResults = map[string]struct{ required, recommended string }{
+	"Article":             {"", "author dateModified datePublished headline image"},
+	"Product":             {"name offers|review|aggregateRating", "brand description image sku"},
+	"Offer":               {"price|priceSpecification", "availability priceCurrency"},
+	"AggregateOffer":      {"lowPrice priceCurrency", "highPrice offerCount"},
+	"Rating":              {"ratingValue", "bestRating worstRating"},
+	"AggregateRating":     {"ratingValue ratingCount|reviewCount", "bestRating worstRating"},
+	"Review":              {"author reviewRating", "datePublished"},
+	"FAQPage":             {"mainEntity", ""},
+	"Question":            {"name acceptedAnswer|suggestedAnswer", ""},
+	"Answer":              {"text", ""},
+	"BreadcrumbList":      {"itemListElement", ""},
+	"ListItem":            {"position name|item", ""},
+	"HowToStep":           {"", "text"},
+	"HowToSection":        {"", ""},
+	"Organization":        {"", "logo name url"},
+	"LocalBusiness":       {"address name", "geo image openingHoursSpecification priceRange telephone url"},
+	"PostalAddress":       {"", "addressCountry addressLocality postalCode streetAddress"},
+	"Event":               {"location name startDate", "description endDate eventStatus image offers organizer performer"},
+	"JobPosting":          {"datePosted description hiringOrganization title jobLocation|applicantLocationRequirements", "baseSalary employmentType validThrough"},
+	"Recipe":              {"image name", "author cookTime datePublished description keywords nutrition prepTime recipeCategory recipeCuisine recipeIngredient recipeInstructions recipeYield totalTime"},
+	"VideoObject":         {"name thumbnailUrl uploadDate", "contentUrl|embedUrl description duration"},
+	"SoftwareApplication": {"name offers aggregateRating|review", "applicationCategory operatingSystem"},
+	"Course":              {"description name", "provider"},
+}
+
+// visibleTypes are the types whose name or headline Google expects to be
+// visible on the page
+var visibleTypes = []string{"Article", "Course", "Event", "JobPosting", "Product", "Question", "Recipe", "SoftwareApplication"}
+
+// schemaVocabulary is schemaTypes with every type's own and inherited
+// properties
+var schemaVocabulary = buildVocabulary()
+
+// vocabularyType is a schema.org type with its ancestors, nearest first, and
+// every property it accepts
+type vocabularyType struct {
+	ancestors  []string
+	properties map[string]bool
+}
+
+func buildVocabulary() map[string]*vocabularyType {
+	vocab := make(map[string]*vocabularyType, len(schemaTypes))
+	var resolve func(name string) *vocabularyType
+	resolve = func(name string) *vocabularyType {
+		if t, ok := vocab[name]; ok {
+			return t
+		}
+		def := schemaTypes[name]
+		t := &vocabularyType{ancestors: []string{name}, properties: make(map[string]bool)}
+		for _, p := range strings.Fields(def.properties) {
+			t.properties[p] = true
+		}
+		for _, parent := range strings.Fields(def.parents) {
+			pt := resolve(parent)
+			for _, a := range pt.ancestors {
+				if !slices.Contains(t.ancestors, a) {
+					t.ancestors = append(t.ancestors, a)
+				}
+			}
+			for p := range pt.properties {
+				t.properties[p] = true
+			}
+		}
+		vocab[name] = t
+		return t
+	}
+	for name := range schemaTypes {
+		resolve(name)
+	}
+	return vocab
+}
+
+// CheckStructuredData validates items against the bundled schema.org
+// vocabulary and Google's rich result requirements, and checks that the names
+// and headlines of content types appear in visible, the page's visible text.
+// It returns every schema.org type the items use.
+func CheckStructuredData(items []models.StructuredData, visible string) []string {
+	text := normalizeText(visible)
+	types := make(map[string]bool)
+	for i := range items {
+		item := &items[i]
+		if item.Data == nil {
+			continue
+		}
+		item.Types = itemTypes(item.Data)
+		if len(item.Types) == 0 {
+			item.Errors = append(item.Errors, "item has no @type")
+		}
+		checkItem(item, item.Data, "", text, types)
+		item.Missing = uniqueStrings(item.Missing)
+		item.MissingRecommended = uniqueStrings(item.MissingRecommended)
+		item.Warnings = uniqueStrings(item.Warnings)
+		item.Mismatches = uniqueStrings(item.Mismatches)
+	}
+
+	names := make([]string, 0, len(types))
+	for t := range types {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkItem validates one item, found at path, and the items nested in it
+func checkItem(item *models.StructuredData, data map[string]any, path, text string, types map[string]bool) {
+	names := itemTypes(data)
+	var known []*vocabularyType
+	for _, name := range names {
+		types[name] = true
+		if t, ok := schemaVocabulary[name]; ok {
+			known = append(known, t)
+		} else {
+			item.Warnings = append(item.Warnings, fmt.Sprintf("%s: %s is not a schema.org type", pathName(path), name))
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if len(known) > 0 && len(known) == len(names) {
+		for _, key := range keys {
+			if strings.HasPrefix(key, "@") || slices.ContainsFunc(known, func(t *vocabularyType) bool { return t.properties[key] }) {
+				continue
+			}
+			item.Warnings = append(item.Warnings, fmt.Sprintf("%s is not a property of %s", joinPath(path, key), names[0]))
+		}
+	}
+
+	for _, t := range known {
+		for _, ancestor := range t.ancestors {
+			rule, ok := richResults[ancestor]
+			if !ok {
+				continue
+			}
+			for _, prop := range strings.Fields(rule.required) {
+				if !hasProperty(data, prop) {
+					item.Missing = append(item.Missing, joinPath(path, prop))
+				}
+			}
+			for _, prop := range strings.Fields(rule.recommended) {
+				if !hasProperty(data, prop) {
+					item.MissingRecommended = append(item.MissingRecommended, joinPath(path, prop))
+				}
+			}
+			break
+		}
+
+		if slices.ContainsFunc(t.ancestors, func(a string) bool { return slices.Contains(visibleTypes, a) }) {
+			for _, key := range []string{"headline", "name"} {
+				value, ok := data[key].(string)
+				if !ok || strings.TrimSpace(value) == "" {
+					continue
+				}
+				if !strings.Contains(text, normalizeText(html.UnescapeString(value))) {
+					item.Mismatches = append(item.Mismatches, fmt.Sprintf("%s %q does not appear on the page", joinPath(path, key), value))
+				}
+				break
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, "@") {
+			continue
+		}
+		values, ok := data[key].([]any)
+		if !ok {
+			values = []any{data[key]}
+		}
+		for _, v := range values {
+			if nested, ok := v.(map[string]any); ok {
+				checkItem(item, nested, joinPath(path, key), text, types)
+			}
+		}
+	}
+}
+
+// itemTypes returns the schema.org names of an item's @type
+func itemTypes(data map[string]any) []string {
+	var types []string
+	switch t := data["@type"].(type) {
+	case string:
+		types = append(types, schemaName(strings.TrimSpace(t)))
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, schemaName(strings.TrimSpace(s)))
+			}
+		}
+	}
+	return types
+}
+
+// hasProperty reports whether data has a non-empty value for prop, or for
+// one of its alternatives when prop is written as "a|b"
+func hasProperty(data map[string]any, prop string) bool {
+	for _, name := range strings.Split(prop, "|") {
+		switch v := data[name].(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(v) != "" {
+				return true
+			}
+		case []any:
+			if len(v) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath appends a property to the dotted path of an item
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pathName describes the item at path in messages
+func pathName(path string) string {
+	if path == "" {
+		return "item"
+	}
+	return path
+}
+
+// normalizeText lowercases text and collapses its whitespace
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,294 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// StructuredData returns the JSON-LD, Microdata and RDFa items of the
+// document, unvalidated. Every format is expressed the way JSON-LD would be,
+// with schema.org types and properties under their short names.
+func (d *HTMLDocument) StructuredData() []models.StructuredData {
+	var items []models.StructuredData
+	var extract func(n *html.Node, vocab string)
+	extract = func(n *html.Node, vocab string) {
+		if n.Type == html.ElementNode && n.Namespace == "" {
+			if v, ok := attrOK(n, "vocab"); ok {
+				vocab = v
+			}
+			_, scoped := attrOK(n, "itemscope")
+			_, itemprop := attrOK(n, "itemprop")
+			_, property := attrOK(n, "property")
+			switch {
+			case n.Data == "script":
+				mediaType, _, _ := strings.Cut(strings.ToLower(attr(n, "type")), ";")
+				if strings.TrimSpace(mediaType) == "application/ld+json" {
+					items = append(items, parseJSONLD(extractText(n))...)
+				}
+				return
+			case scoped && !itemprop:
+				items = append(items, models.StructuredData{Format: models.FormatMicrodata, Data: d.microdataItem(n)})
+			case !property && len(rdfaTypes(n, vocab)) > 0:
+				items = append(items, models.StructuredData{Format: models.FormatRDFa, Data: d.rdfaItem(n, vocab)})
+			}
+		}
+		// Items nested without a property are top-level items of their own
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c, vocab)
+		}
+	}
+
+	extract(d.Root, "")
+	return items
+}
+
+// parseJSONLD reads the items of a JSON-LD script, unwrapping arrays and
+// @graph containers
+func parseJSONLD(text string) []models.StructuredData {
+	var v any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &v); err != nil {
+		return []models.StructuredData{{Format: models.FormatJSONLD, Errors: []string{fmt.Sprintf("invalid JSON: %v", err)}}}
+	}
+
+	var items []models.StructuredData
+	var add func(v, context any)
+	add = func(v, context any) {
+		switch v := v.(type) {
+		case []any:
+			for _, e := range v {
+				add(e, context)
+			}
+		case map[string]any:
+			if c, ok := v["@context"]; ok {
+				context = c
+			}
+			if graph, ok := v["@graph"]; ok {
+				add(graph, context)
+				if _, typed := v["@type"]; !typed {
+					return
+				}
+			}
+			item := models.StructuredData{Format: models.FormatJSONLD, Data: v}
+			if !isSchemaContext(context) {
+				item.Errors = append(item.Errors, "@context does not refer to schema.org")
+			}
+			items = append(items, item)
+		default:
+			items = append(items, models.StructuredData{Format: models.FormatJSONLD, Errors: []string{"item is not a JSON object"}})
+		}
+	}
+
+	add(v, nil)
+	return items
+}
+
+// isSchemaContext reports whether a JSON-LD @context makes schema.org the
+// vocabulary
+func isSchemaContext(context any) bool {
+	switch c := context.(type) {
+	case string:
+		return isSchemaOrg(c)
+	case map[string]any:
+		vocab, _ := c["@vocab"].(string)
+		return isSchemaOrg(vocab)
+	case []any:
+		for _, e := range c {
+			if isSchemaContext(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSchemaOrg reports whether a vocabulary URL is schema.org's
+func isSchemaOrg(vocab string) bool {
+	vocab = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(vocab)), "/")
+	return vocab == "http://schema.org" || vocab == "https://schema.org"
+}
+
+// schemaName shortens a schema.org type or property URL, or a schema: CURIE,
+// to its name. Anything else is returned unchanged.
+func schemaName(name string) string {
+	for _, prefix := range []string{"https://schema.org/", "http://schema.org/", "schema:"} {
+		if len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+// typeValue expresses types as a JSON-LD @type
+func typeValue(types []string) any {
+	if len(types) == 1 {
+		return types[0]
+	}
+	values := make([]any, len(types))
+	for i, t := range types {
+		values[i] = t
+	}
+	return values
+}
+
+// addValue adds a property value to an item, turning repeated properties
+// into arrays
+func addValue(item map[string]any, name string, value any) {
+	switch existing := item[name].(type) {
+	case nil:
+		item[name] = value
+	case []any:
+		item[name] = append(existing, value)
+	default:
+		item[name] = []any{existing, value}
+	}
+}
+
+// microdataItem reads the itemscope element n
+func (d *HTMLDocument) microdataItem(n *html.Node) map[string]any {
+	item := make(map[string]any)
+	var types []string
+	for _, t := range strings.Fields(attr(n, "itemtype")) {
+		types = append(types, schemaName(t))
+	}
+	if len(types) > 0 {
+		item["@type"] = typeValue(types)
+	}
+	if id := strings.TrimSpace(attr(n, "itemid")); id != "" {
+		item["@id"] = d.resolve(id)
+	}
+
+	var props func(p *html.Node)
+	props = func(p *html.Node) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			_, scoped := attrOK(c, "itemscope")
+			if names := strings.Fields(attr(c, "itemprop")); len(names) > 0 {
+				var value any
+				if scoped {
+					value = d.microdataItem(c)
+				} else {
+					value = d.elementValue(c, false)
+				}
+				for _, name := range names {
+					addValue(item, schemaName(name), value)
+				}
+			}
+			if !scoped {
+				props(c)
+			}
+		}
+	}
+
+	props(n)
+	return item
+}
+
+// rdfaTypes returns the schema.org types an element declares with typeof
+func rdfaTypes(n *html.Node, vocab string) []string {
+	var types []string
+	for _, t := range strings.Fields(attr(n, "typeof")) {
+		if name := schemaName(t); name != t || (isSchemaOrg(vocab) && !strings.Contains(t, ":")) {
+			types = append(types, name)
+		}
+	}
+	return types
+}
+
+// rdfaItem reads the typeof element n
+func (d *HTMLDocument) rdfaItem(n *html.Node, vocab string) map[string]any {
+	item := map[string]any{"@type": typeValue(rdfaTypes(n, vocab))}
+	if id := strings.TrimSpace(attr(n, "resource")); id != "" {
+		item["@id"] = d.resolve(id)
+	}
+
+	var props func(p *html.Node, vocab string)
+	props = func(p *html.Node, vocab string) {
+		for c := p.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if v, ok := attrOK(c, "vocab"); ok {
+				vocab = v
+			}
+			typed := len(rdfaTypes(c, vocab)) > 0
+			var names []string
+			for _, name := range strings.Fields(attr(c, "property")) {
+				if short := schemaName(name); short != name || (isSchemaOrg(vocab) && !strings.Contains(name, ":")) {
+					names = append(names, short)
+				}
+			}
+			if len(names) > 0 {
+				var value any
+				if typed {
+					value = d.rdfaItem(c, vocab)
+				} else {
+					value = d.elementValue(c, true)
+				}
+				for _, name := range names {
+					addValue(item, name, value)
+				}
+			}
+			if !typed {
+				props(c, vocab)
+			}
+		}
+	}
+
+	props(n, vocab)
+	return item
+}
+
+// elementValue is the value of a Microdata or RDFa property element: an
+// attribute for elements that carry one, otherwise the element's text. RDFa
+// lets any element override it with content or point elsewhere with resource.
+func (d *HTMLDocument) elementValue(n *html.Node, rdfa bool) string {
+	if rdfa {
+		if content, ok := attrOK(n, "content"); ok {
+			return strings.TrimSpace(content)
+		}
+		if resource, ok := attrOK(n, "resource"); ok {
+			return d.resolve(resource)
+		}
+	}
+	switch n.Data {
+	case "meta":
+		return strings.TrimSpace(attr(n, "content"))
+	case "a", "area", "link":
+		if href, ok := attrOK(n, "href"); ok {
+			return d.resolve(href)
+		}
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		if src, ok := attrOK(n, "src"); ok {
+			return d.resolve(src)
+		}
+	case "object":
+		if data, ok := attrOK(n, "data"); ok {
+			return d.resolve(data)
+		}
+	case "data", "meter":
+		if value, ok := attrOK(n, "value"); ok {
+			return strings.TrimSpace(value)
+		}
+	case "time":
+		if datetime, ok := attrOK(n, "datetime"); ok {
+			return strings.TrimSpace(datetime)
+		}
+	}
+	return strings.Join(strings.Fields(extractText(n)), " ")
+}
+
+// resolve makes a reference absolute against the document base
+func (d *HTMLDocument) resolve(ref string) string {
+	ref = cleanHref(ref)
+	if u, err := d.Base.Parse(ref); err == nil {
+		return u.String()
+	}
+	return ref
+}
@@ -0,0 +1,286 @@
+package extractor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+
+	"github.com/amosWeiskopf/crawlsmith/internal/models"
+)
+
+// elementByID returns the element of doc whose id is id
+func elementByID(t *testing.T, doc *HTMLDocument, id string) *html.Node {
+	var find func(n *html.Node) *html.Node
+	find = func(n *html.Node) *html.Node {
+		if n.Type == html.ElementNode && attr(n, "id") == id {
+			return n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if found := find(c); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	n := find(doc.Root)
+	require.NotNil(t, n, "no element with id %q", id)
+	return n
+}
+
+func TestParseJSONLD(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []models.StructuredData
+	}{
+		{
+			name: "object",
+			text: `{"@context": "https://schema.org", "@type": "Organization", "name": "Acme"}`,
+			want: []models.StructuredData{{Format: models.FormatJSONLD, Data: map[string]any{
+				"@context": "https://schema.org", "@type": "Organization", "name": "Acme",
+			}}},
+		},
+		{
+			name: "array",
+			text: `[{"@context": "http://schema.org/", "@type": "Person"}, {"@type": "Thing"}]`,
+			want: []models.StructuredData{
+				{Format: models.FormatJSONLD, Data: map[string]any{"@context": "http://schema.org/", "@type": "Person"}},
+				{Format: models.FormatJSONLD, Data: map[string]any{"@type": "Thing"}, Errors: []string{"@context does not refer to schema.org"}},
+			},
+		},
+		{
+			name: "graph",
+			text: `{"@context": {"@vocab": "https://schema.org/"}, "@graph": [{"@type": "WebSite"}, {"@type": "WebPage"}]}`,
+			want: []models.StructuredData{
+				{Format: models.FormatJSONLD, Data: map[string]any{"@type": "WebSite"}},
+				{Format: models.FormatJSONLD, Data: map[string]any{"@type": "WebPage"}},
+			},
+		},
+		{
+			name: "typed graph container",
+			text: `{"@context": "https://schema.org", "@type": "WebPage", "@graph": {"@type": "Person"}}`,
+			want: []models.StructuredData{
+				{Format: models.FormatJSONLD, Data: map[string]any{"@type": "Person"}},
+				{Format: models.FormatJSONLD, Data: map[string]any{"@context": "https://schema.org", "@type": "WebPage", "@graph": map[string]any{"@type": "Person"}}},
+			},
+		},
+		{
+			name: "foreign context",
+			text: `{"@context": "https://example.com/vocab", "@type": "Widget"}`,
+			want: []models.StructuredData{{
+				Format: models.FormatJSONLD,
+				Data:   map[string]any{"@context": "https://example.com/vocab", "@type": "Widget"},
+				Errors: []string{"@context does not refer to schema.org"},
+			}},
+		},
+		{
+			name: "not an object",
+			text: `["https://schema.org"]`,
+			want: []models.StructuredData{{Format: models.FormatJSONLD, Errors: []string{"item is not a JSON object"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseJSONLD(tt.text))
+		})
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		items := parseJSONLD(`{"@type": "Organization",}`)
+		require.Len(t, items, 1)
+		assert.Equal(t, models.FormatJSONLD, items[0].Format)
+		assert.Nil(t, items[0].Data)
+		require.Len(t, items[0].Errors, 1)
+		assert.Contains(t, items[0].Errors[0], "invalid JSON")
+	})
+}
+
+func TestIsSchemaContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		context any
+		want    bool
+	}{
+		{"https", "https://schema.org", true},
+		{"http with slash", "http://schema.org/", true},
+		{"case and spaces", " HTTPS://Schema.org ", true},
+		{"vocab", map[string]any{"@vocab": "https://schema.org/"}, true},
+		{"list", []any{"https://www.w3.org/ns/activitystreams", map[string]any{"@vocab": "http://schema.org"}}, true},
+		{"missing", nil, false},
+		{"other vocabulary", "https://example.com/schema.org", false},
+		{"prefix only", map[string]any{"schema": "https://schema.org/"}, false},
+		{"list without schema.org", []any{"https://example.com"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isSchemaContext(tt.context))
+		})
+	}
+}
+
+func TestMicrodataItem(t *testing.T) {
+	doc, err := Parse(`<div id="product" itemscope itemtype="https://schema.org/Product" itemid="/p/1">
+		<h1 itemprop="name">Anvil</h1>
+		<img itemprop="image" src="/anvil.jpg">
+		<div itemprop="offers" itemscope itemtype="http://schema.org/Offer">
+			<meta itemprop="price" content="19.99">
+			<span itemprop="name">Not the product name</span>
+		</div>
+		<p><span itemprop="color">black</span> and <span itemprop="color">grey</span></p>
+		<link itemprop="url sameAs" href="https://example.com/anvil">
+	</div>`, "https://example.com/shop/")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"@type":  "Product",
+		"@id":    "https://example.com/p/1",
+		"name":   "Anvil",
+		"image":  "https://example.com/anvil.jpg",
+		"offers": map[string]any{"@type": "Offer", "price": "19.99", "name": "Not the product name"},
+		"color":  []any{"black", "grey"},
+		"url":    "https://example.com/anvil",
+		"sameAs": "https://example.com/anvil",
+	}, doc.microdataItem(elementByID(t, doc, "product")))
+}
+
+func TestRDFaItem(t *testing.T) {
+	doc, err := Parse(`<div id="person" vocab="https://schema.org/" typeof="Person" resource="#me">
+		<span property="name">Jane Doe</span>
+		<span property="jobTitle" content="Engineer">Builds things</span>
+		<span property="foaf:nick">jd</span>
+		<div property="address" typeof="PostalAddress">
+			<span property="addressLocality">Berlin</span>
+		</div>
+		<div vocab="https://example.com/vocab/">
+			<span property="hobby">Chess</span>
+			<span property="schema:email">jane@example.com</span>
+		</div>
+	</div>`, "https://example.com/about")
+	require.NoError(t, err)
+
+	person := elementByID(t, doc, "person")
+	assert.Equal(t, []string{"Person"}, rdfaTypes(person, "https://schema.org/"))
+	assert.Empty(t, rdfaTypes(person, "https://example.com/vocab/"))
+	assert.Equal(t, map[string]any{
+		"@type":    "Person",
+		"@id":      "https://example.com/about#me",
+		"name":     "Jane Doe",
+		"jobTitle": "Engineer",
+		"address":  map[string]any{"@type": "PostalAddress", "addressLocality": "Berlin"},
+		"email":    "jane@example.com",
+	}, doc.rdfaItem(person, "https://schema.org/"))
+}
+
+func TestStructuredData(t *testing.T) {
+	doc, err := Parse(`<html><head>
+		<script type="application/ld+json; charset=utf-8">{"@context": "https://schema.org", "@type": "WebSite", "name": "Example"}</script>
+		<script type="application/ld+json">not json</script>
+	</head><body>
+		<div itemscope itemtype="https://schema.org/Organization">
+			<span itemprop="name">Acme</span>
+			<div itemscope itemtype="https://schema.org/Person"><span itemprop="name">Founder</span></div>
+		</div>
+		<div vocab="https://schema.org/" typeof="Event"><span property="name">Launch</span></div>
+		<div typeof="foaf:Person"><span property="foaf:name">Ignored</span></div>
+	</body></html>`, "https://example.com/")
+	require.NoError(t, err)
+
+	items := doc.StructuredData()
+	require.Len(t, items, 5)
+	assert.Equal(t, map[string]any{"@context": "https://schema.org", "@type": "WebSite", "name": "Example"}, items[0].Data)
+	assert.Equal(t, models.FormatJSONLD, items[1].Format)
+	assert.Len(t, items[1].Errors, 1)
+	assert.Equal(t, models.StructuredData{Format: models.FormatMicrodata, Data: map[string]any{"@type": "Organization", "name": "Acme"}}, items[2])
+	assert.Equal(t, models.StructuredData{Format: models.FormatMicrodata, Data: map[string]any{"@type": "Person", "name": "Founder"}}, items[3],
+		"an itemscope without itemprop is an item of its own")
+	assert.Equal(t, models.StructuredData{Format: models.FormatRDFa, Data: map[string]any{"@type": "Event", "name": "Launch"}}, items[4])
+}
+
+func TestCheckStructuredData(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]any
+		visible string
+		want    models.StructuredData
+	}{
+		{
+			name:    "complete product",
+			data:    map[string]any{"@type": "Product", "name": "Anvil", "brand": "Acme", "description": "Heavy", "image": "a.jpg", "sku": "A1", "offers": map[string]any{"@type": "Offer", "price": "19.99", "priceCurrency": "EUR", "availability": "InStock"}},
+			visible: "The ANVIL\n is heavy",
+			want:    models.StructuredData{Types: []string{"Product"}},
+		},
+		{
+			name:    "missing required alternatives",
+			data:    map[string]any{"@type": "Product", "name": "Anvil", "review": []any{}},
+			visible: "Anvil",
+			want: models.StructuredData{
+				Types:              []string{"Product"},
+				Missing:            []string{"offers|review|aggregateRating"},
+				MissingRecommended: []string{"brand", "description", "image", "sku"},
+			},
+		},
+		{
+			name:    "nested item",
+			data:    map[string]any{"@type": "Product", "name": "Anvil", "offers": []any{map[string]any{"@type": "Offer", "price": ""}}, "brand": "Acme", "description": "Heavy", "image": "a.jpg", "sku": "A1"},
+			visible: "Anvil",
+			want: models.StructuredData{
+				Types:              []string{"Product"},
+				Missing:            []string{"offers.price|priceSpecification"},
+				MissingRecommended: []string{"offers.availability", "offers.priceCurrency"},
+			},
+		},
+		{
+			name:    "subtype uses nearest rules",
+			data:    map[string]any{"@type": "https://schema.org/NewsArticle", "headline": "Big news", "author": "Jane", "dateModified": "2024", "datePublished": "2024", "image": "a.jpg"},
+			visible: "Other text",
+			want: models.StructuredData{
+				Types:      []string{"NewsArticle"},
+				Mismatches: []string{`headline "Big news" does not appear on the page`},
+			},
+		},
+		{
+			name:    "unknown type and property",
+			data:    map[string]any{"@type": []any{"Organization"}, "colour": "red", "author": map[string]any{"@type": "Robot"}},
+			visible: "",
+			want: models.StructuredData{
+				Types:              []string{"Organization"},
+				MissingRecommended: []string{"logo", "name", "url"},
+				Warnings:           []string{"author is not a property of Organization", "colour is not a property of Organization", "author: Robot is not a schema.org type"},
+			},
+		},
+		{
+			name: "untyped",
+			data: map[string]any{"name": "Anvil"},
+			want: models.StructuredData{Errors: []string{"item has no @type"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []models.StructuredData{{Data: tt.data}}
+			CheckStructuredData(items, tt.visible)
+			got := items[0]
+			assert.Equal(t, tt.want.Types, got.Types)
+			assert.Equal(t, tt.want.Errors, got.Errors)
+			assert.ElementsMatch(t, tt.want.Missing, got.Missing)
+			assert.ElementsMatch(t, tt.want.MissingRecommended, got.MissingRecommended)
+			assert.ElementsMatch(t, tt.want.Warnings, got.Warnings)
+			assert.ElementsMatch(t, tt.want.Mismatches, got.Mismatches)
+		})
+	}
+
+	t.Run("types", func(t *testing.T) {
+		items := []models.StructuredData{
+			{Data: map[string]any{"@type": "Recipe", "name": "Soup", "image": "s.jpg", "author": map[string]any{"@type": "Person", "name": "Jane"}}},
+			{Errors: []string{"invalid JSON"}},
+			{Data: map[string]any{"@type": []any{"Product", "Thing"}, "name": "Soup"}},
+		}
+		assert.Equal(t, []string{"Person", "Product", "Recipe", "Thing"}, CheckStructuredData(items, "Soup"))
+		assert.Equal(t, []string{"invalid JSON"}, items[1].Errors)
+		assert.Nil(t, items[1].Types)
+	})
+}
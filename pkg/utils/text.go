@@ -12,7 +12,7 @@ var stopWords = map[string]bool{
 	"be": true, "by": true, "for": true, "from": true, "has": true, "he": true,
 	"in": true, "is": true, "it": true, "its": true, "of": true, "on": true,
 	"that": true, "the": true, "to": true, "was": true, "will": true, "with": true,
-	"the": true, "this": true, "but": true, "they": true, "have": true, "had": true,
+	"this": true, "but": true, "they": true, "have": true, "had": true,
 	"were": true, "been": true, "their": true, "she": true, "which": true, "do": true,
 	"or": true, "if": true, "not": true, "what": true, "there": true, "can": true,
 	"out": true, "up": true, "one": true, "about": true, "more": true, "so": true,
@@ -110,31 +110,14 @@ func TruncateText(text string, maxLength int) string {
 	return truncated + "..."
 }
 
-// NormalizeURL normalizes a URL for consistent comparison
+// NormalizeURL returns the canonical form of a URL using the default
+// canonicalization rules, or the URL unchanged if it cannot be parsed
 func NormalizeURL(url string) string {
-	// Remove trailing slash
-	url = strings.TrimSuffix(url, "/")
-	
-	// Remove fragment
-	if idx := strings.Index(url, "#"); idx > 0 {
-		url = url[:idx]
+	canonical, err := defaultCanonicalizer.Canonicalize(url)
+	if err != nil {
+		return url
 	}
-	
-	// Convert to lowercase for domain part
-	if idx := strings.Index(url, "://"); idx > 0 {
-		protocol := url[:idx+3]
-		rest := url[idx+3:]
-		
-		if slashIdx := strings.Index(rest, "/"); slashIdx > 0 {
-			domain := strings.ToLower(rest[:slashIdx])
-			path := rest[slashIdx:]
-			url = protocol + domain + path
-		} else {
-			url = protocol + strings.ToLower(rest)
-		}
-	}
-	
-	return url
+	return canonical
 }
 
 // IsValidURL checks if a string is a valid URL
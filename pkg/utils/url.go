@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// trackingParams only carry campaign or click tracking data and never change
+// what a page shows. A trailing * matches any suffix.
+var trackingParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "gbraid", "wbraid", "fbclid", "msclkid",
+	"yclid", "twclid", "igshid", "mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+// indexFiles are directory index documents served for their directory
+var indexFiles = []string{
+	"index.html", "index.htm", "index.php", "default.htm", "default.html", "default.asp", "default.aspx",
+}
+
+// ParamRules limits the query parameters kept for a site. When Allow is set
+// only those parameters are kept; Deny parameters are always removed. Names
+// are case-insensitive and a trailing * matches any suffix.
+type ParamRules struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// CanonicalOptions configures a Canonicalizer. The zero value applies every
+// default rule.
+type CanonicalOptions struct {
+	StripTrailingSlash bool `json:"strip_trailing_slash,omitempty"` // Treat /about/ and /about as one URL
+	KeepIndexFiles     bool `json:"keep_index_files,omitempty"`     // Keep /index.html and friends instead of folding them into /
+	KeepTrackingParams bool `json:"keep_tracking_params,omitempty"` // Keep utm_*, gclid, fbclid and similar parameters
+
+	// Params applies to every site; Sites adds rules per host, which also
+	// cover the host's subdomains. Only the most specific matching host's
+	// rules apply.
+	Params ParamRules            `json:"params,omitempty"`
+	Sites  map[string]ParamRules `json:"sites,omitempty"`
+}
+
+// Canonicalizer maps the many spellings of a URL onto one canonical form
+type Canonicalizer struct {
+	opts CanonicalOptions
+}
+
+// NewCanonicalizer creates a canonicalizer with the given options
+func NewCanonicalizer(opts CanonicalOptions) *Canonicalizer {
+	return &Canonicalizer{opts: opts}
+}
+
+// defaultCanonicalizer backs NormalizeURL
+var defaultCanonicalizer = NewCanonicalizer(CanonicalOptions{})
+
+// Canonicalize parses rawURL and returns its canonical form
+func (c *Canonicalizer) Canonicalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	return c.URL(u), nil
+}
+
+// URL returns the canonical form of u. The scheme and host are lowercased,
+// IDN hosts converted to punycode, default ports, fragments and dot segments
+// removed, percent-encoding normalized, index documents folded into their
+// directory and query parameters filtered and sorted.
+func (c *Canonicalizer) URL(u *url.URL) string {
+	if u.Opaque != "" || u.Host == "" {
+		n := *u
+		n.Fragment, n.RawFragment = "", ""
+		return n.String()
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && !isDefaultPort(scheme, port) {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme + "://")
+	if u.User != nil {
+		b.WriteString(u.User.String() + "@")
+	}
+	b.WriteString(host)
+	b.WriteString(c.path(u.EscapedPath()))
+	if query := c.query(u.RawQuery, strings.Trim(host, "[]")); query != "" {
+		b.WriteString("?" + query)
+	}
+	return b.String()
+}
+
+// path normalizes an escaped path
+func (c *Canonicalizer) path(p string) string {
+	p = removeDotSegments(normalizeEscapes(p, false))
+	if p == "" {
+		p = "/"
+	}
+	if !c.opts.KeepIndexFiles {
+		dir, file := p[:strings.LastIndex(p, "/")+1], p[strings.LastIndex(p, "/")+1:]
+		for _, index := range indexFiles {
+			if strings.EqualFold(file, index) {
+				p = dir
+				break
+			}
+		}
+	}
+	if c.opts.StripTrailingSlash && len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return p
+}
+
+// query drops unwanted parameters from a raw query and sorts the rest by
+// name, keeping the order of repeated parameters
+func (c *Canonicalizer) query(rawQuery, host string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	allow, deny := c.opts.Params.Allow, c.opts.Params.Deny
+	if !c.opts.KeepTrackingParams {
+		deny = append(deny[:len(deny):len(deny)], trackingParams...)
+	}
+	if rules, ok := c.siteRules(host); ok {
+		if len(rules.Allow) > 0 {
+			allow = rules.Allow
+		}
+		deny = append(deny[:len(deny):len(deny)], rules.Deny...)
+	}
+
+	type param struct{ name, pair string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		pair = normalizeEscapes(pair, true)
+		rawName, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if matchParam(deny, name) || (len(allow) > 0 && !matchParam(allow, name)) {
+			continue
+		}
+		params = append(params, param{name: name, pair: pair})
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].name < params[j].name
+	})
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// siteRules returns the rules of the longest site that host is or is a
+// subdomain of
+func (c *Canonicalizer) siteRules(host string) (ParamRules, bool) {
+	var match string
+	for site := range c.opts.Sites {
+		s := strings.ToLower(site)
+		if (host == s || strings.HasSuffix(host, "."+s)) && (len(s) > len(match) || (len(s) == len(match) && site < match)) {
+			match = site
+		}
+	}
+	if match == "" {
+		return ParamRules{}, false
+	}
+	return c.opts.Sites[match], true
+}
+
+// matchParam reports whether name matches one of patterns
+func matchParam(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+		if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefaultPort reports whether port is the default for scheme
+func isDefaultPort(scheme, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters, uppercases
+// the remaining escapes and escapes bytes that may not appear unencoded.
+// In queries a space is kept as +.
+func normalizeEscapes(s string, query bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hex[decoded>>4])
+				b.WriteByte(hex[decoded&15])
+			}
+			i += 2
+		case ch == ' ' && query:
+			b.WriteByte('+')
+		case ch <= ' ' || ch >= 0x7f || ch == '%' || ch == '"' || ch == '<' || ch == '>' || ch == '\\' || ch == '^' || ch == '`' || ch == '{' || ch == '|' || ch == '}':
+			b.WriteByte('%')
+			b.WriteByte(hex[ch>>4])
+			b.WriteByte(hex[ch&15])
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves . and .. segments as described in RFC 3986 5.2.4
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+			continue
+		}
+		if last {
+			// A path ending in a dot segment names a directory
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	canon := NewCanonicalizer(CanonicalOptions{
+		Sites: map[string]ParamRules{
+			"shop.example":     {Allow: []string{"page"}},
+			"old.shop.example": {Allow: []string{"id"}},
+		},
+	})
+	for raw, want := range map[string]string{
+		"HTTPS://Example.COM:443/a/./b/../c?gclid=1":     "https://example.com/a/c",
+		"http://example.com:80/%7euser/%2f%e2%82%ac":     "http://example.com/~user/%2F%E2%82%AC",
+		"http://bücher.example/Default.aspx?z=1&a=2&a=1": "http://xn--bcher-kva.example/?a=2&a=1&z=1",
+		"http://www.shop.example/p?page=2&color=red":     "http://www.shop.example/p?page=2",
+		"http://old.shop.example/p?page=2&id=7":          "http://old.shop.example/p?id=7",
+		"mailto:info@example.com":                        "mailto:info@example.com",
+	} {
+		got, err := canon.Canonicalize(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, raw)
+	}
+}
+
+func TestCanonicalizeOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts CanonicalOptions
+		raw  string
+		want string
+	}{
+		{"default keeps trailing slash", CanonicalOptions{}, "https://example.com/about/", "https://example.com/about/"},
+		{"strip trailing slash", CanonicalOptions{StripTrailingSlash: true}, "https://example.com/about/", "https://example.com/about"},
+		{"keep index files", CanonicalOptions{KeepIndexFiles: true}, "https://example.com/docs/index.html", "https://example.com/docs/index.html"},
+		{"keep tracking params", CanonicalOptions{KeepTrackingParams: true}, "https://example.com/?utm_source=mail&b=1", "https://example.com/?b=1&utm_source=mail"},
+		{"deny params", CanonicalOptions{Params: ParamRules{Deny: []string{"Session*"}}}, "https://example.com/?sessionid=42&a=1", "https://example.com/?a=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewCanonicalizer(tt.opts).Canonicalize(tt.raw)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
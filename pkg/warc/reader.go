@@ -0,0 +1,70 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxRecordSize caps the content of a record, which is read into memory
+const maxRecordSize = 64 << 20
+
+// Reader reads records from a WARC file, compressed per record with gzip or
+// not compressed at all
+type Reader struct {
+	br *bufio.Reader
+	gz *gzip.Reader
+}
+
+// NewReader creates a reader for r, detecting gzip compression
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read WARC file: %w", err)
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		// Concatenated gzip members read as one stream
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WARC file: %w", err)
+		}
+		return &Reader{br: bufio.NewReader(gz), gz: gz}, nil
+	}
+	return &Reader{br: br}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one
+func (r *Reader) Next() (*Record, error) {
+	rec, length, err := parseHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("WARC record %s is %d bytes, more than the %d allowed", rec.ID, length, maxRecordSize)
+	}
+	rec.Content = make([]byte, length)
+	if _, err := io.ReadFull(r.br, rec.Content); err != nil {
+		return nil, fmt.Errorf("failed to read WARC record %s: %w", rec.ID, unexpected(err))
+	}
+	return rec, nil
+}
+
+// Close releases the decompressor; it does not close the underlying reader
+func (r *Reader) Close() error {
+	if r.gz != nil {
+		return r.gz.Close()
+	}
+	return nil
+}
+
+// line reads one line without its line ending
+func (r *Reader) line() (string, error) {
+	line, err := r.br.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
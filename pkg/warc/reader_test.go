@@ -0,0 +1,157 @@
+package warc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads every record of the WARC file at path
+func readAll(t *testing.T, path string) []*Record {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := NewReader(f)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var records []*Record
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, rec)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	w, err := NewWriter(t.TempDir(), "crawl", 0, "crawlsmith/test")
+	require.NoError(t, err)
+
+	date := time.Date(2024, 5, 12, 10, 30, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	request := &Record{
+		Type:        TypeRequest,
+		Date:        date,
+		TargetURI:   "https://example.com/",
+		ContentType: ContentTypeHTTPRequest,
+		Content:     []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+	}
+	response := &Record{
+		Type:         TypeResponse,
+		TargetURI:    "https://example.com/",
+		ConcurrentTo: "<urn:uuid:00000000-0000-4000-8000-000000000000>",
+		ContentType:  ContentTypeHTTPResponse,
+		Fields:       map[string]string{"WARC-IP-Address": "192.0.2.1"},
+		Content:      []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html>\r\n\r\n</html>"),
+	}
+	empty := &Record{Type: TypeMetadata, TargetURI: "https://example.com/", ContentType: "application/warc-fields"}
+	require.NoError(t, w.Write(request, response))
+	require.NoError(t, w.Write(empty))
+	require.NoError(t, w.Close())
+
+	files := w.Files()
+	require.Len(t, files, 1)
+	assert.Regexp(t, `^crawl-\d{14}-00000\.warc\.gz$`, filepath.Base(files[0]))
+
+	records := readAll(t, files[0])
+	require.Len(t, records, 4)
+
+	info := records[0]
+	assert.Equal(t, TypeWarcinfo, info.Type)
+	assert.Equal(t, filepath.Base(files[0]), info.Fields["WARC-Filename"])
+	assert.Contains(t, string(info.Content), "software: crawlsmith/test\r\n")
+
+	for i, want := range []*Record{request, response, empty} {
+		got := records[i+1]
+		assert.NotEmpty(t, want.ID, "the writer assigns an ID")
+		assert.Equal(t, want.ID, got.ID)
+		assert.True(t, want.Date.Equal(got.Date), "date %v, want %v", got.Date, want.Date)
+		assert.Equal(t, want.Type, got.Type)
+		assert.Equal(t, want.TargetURI, got.TargetURI)
+		assert.Equal(t, want.ConcurrentTo, got.ConcurrentTo)
+		assert.Equal(t, want.ContentType, got.ContentType)
+		assert.Equal(t, string(want.Content), string(got.Content))
+		assert.Equal(t, Digest(want.Content), got.Fields["WARC-Block-Digest"])
+	}
+	assert.Equal(t, "192.0.2.1", records[2].Fields["WARC-IP-Address"])
+	assert.Equal(t, Digest([]byte("<html>\r\n\r\n</html>")), records[2].Fields["WARC-Payload-Digest"])
+	assert.Empty(t, records[3].Content)
+	assert.NotContains(t, records[3].Fields, "WARC-Payload-Digest")
+}
+
+func TestWriterRotation(t *testing.T) {
+	w, err := NewWriter(t.TempDir(), "crawl", 1, "crawlsmith/test")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		request := &Record{Type: TypeRequest, Content: []byte(fmt.Sprintf("GET /%d HTTP/1.1\r\n\r\n", i))}
+		response := &Record{Type: TypeResponse, Content: []byte("HTTP/1.1 204 No Content\r\n\r\n")}
+		require.NoError(t, w.Write(request, response))
+	}
+	require.NoError(t, w.Close())
+
+	files := w.Files()
+	require.Len(t, files, 3)
+	for i, path := range files {
+		assert.True(t, strings.HasSuffix(path, fmt.Sprintf("-%05d.warc.gz", i)), path)
+		var types []string
+		for _, rec := range readAll(t, path) {
+			types = append(types, rec.Type)
+		}
+		assert.Equal(t, []string{TypeWarcinfo, TypeRequest, TypeResponse}, types, "a request and its response share a file")
+	}
+}
+
+func TestReaderUncompressed(t *testing.T) {
+	var b bytes.Buffer
+	b.Write((&Record{Type: TypeMetadata, ID: "<urn:uuid:1>", Content: []byte("one")}).marshal())
+	b.WriteString("\r\n")
+	b.Write((&Record{Type: TypeMetadata, ID: "<urn:uuid:2>", Content: []byte("two")}).marshal())
+
+	r, err := NewReader(&b)
+	require.NoError(t, err)
+	for _, want := range []string{"one", "two"} {
+		rec, err := r.Next()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(rec.Content))
+	}
+	_, err = r.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, r.Close())
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		// A corrupt Content-Length must not allocate the claimed size
+		{"oversized record", "WARC/1.1\r\nWARC-Record-ID: <urn:uuid:1>\r\nContent-Length: 1099511627776\r\n\r\n", fmt.Sprintf("WARC record <urn:uuid:1> is 1099511627776 bytes, more than the %d allowed", maxRecordSize)},
+		{"not a WARC file", "HTTP/1.1 200 OK\r\n\r\n", `invalid WARC record: unexpected "HTTP/1.1 200 OK"`},
+		{"missing Content-Length", "WARC/1.1\r\nWARC-Record-ID: <urn:uuid:1>\r\n\r\n", "WARC record <urn:uuid:1> has no Content-Length"},
+		{"negative Content-Length", "WARC/1.1\r\nContent-Length: -1\r\n\r\n", `invalid Content-Length "-1"`},
+		{"invalid header line", "WARC/1.1\r\nContent-Length 3\r\n\r\n", `invalid WARC header line "Content-Length 3"`},
+		{"invalid date", "WARC/1.1\r\nWARC-Date: yesterday\r\n", `invalid WARC-Date "yesterday"`},
+		{"truncated header", "WARC/1.1\r\nContent-Length: 3\r\n", io.ErrUnexpectedEOF.Error()},
+		{"truncated content", "WARC/1.1\r\nWARC-Record-ID: <urn:uuid:1>\r\nContent-Length: 10\r\n\r\nshort", "failed to read WARC record <urn:uuid:1>: unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(strings.NewReader(tt.input))
+			require.NoError(t, err)
+			_, err = r.Next()
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
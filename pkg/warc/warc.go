@@ -0,0 +1,170 @@
+// Package warc reads and writes WARC 1.1 archives
+// (https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/).
+package warc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the WARC version written by Writer
+const Version = "WARC/1.1"
+
+// Record types
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeMetadata = "metadata"
+)
+
+// Content types of HTTP request and response blocks
+const (
+	ContentTypeHTTPRequest  = "application/http;msgtype=request"
+	ContentTypeHTTPResponse = "application/http;msgtype=response"
+)
+
+// Record is a single WARC record. Writer fills in ID and Date when they are
+// empty; fields not covered by the struct go in Fields.
+type Record struct {
+	Type         string
+	ID           string
+	Date         time.Time
+	TargetURI    string
+	ConcurrentTo string
+	ContentType  string
+	Fields       map[string]string
+	Content      []byte
+}
+
+// NewID returns a fresh record ID of the form <urn:uuid:...>
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Digest returns the WARC digest of data, as sha1:<base32>
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// Payload returns the body of an HTTP message block, after its headers
+func Payload(block []byte) []byte {
+	if i := bytes.Index(block, []byte("\r\n\r\n")); i >= 0 {
+		return block[i+4:]
+	}
+	return nil
+}
+
+// marshal serializes rec, including the two CRLFs that end every record
+func (rec *Record) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteString(Version + "\r\n")
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+		}
+	}
+	field("WARC-Type", rec.Type)
+	field("WARC-Record-ID", rec.ID)
+	field("WARC-Date", rec.Date.UTC().Format(time.RFC3339Nano))
+	field("WARC-Target-URI", rec.TargetURI)
+	field("WARC-Concurrent-To", rec.ConcurrentTo)
+	field("Content-Type", rec.ContentType)
+	names := make([]string, 0, len(rec.Fields))
+	for name := range rec.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field(name, rec.Fields[name])
+	}
+	field("WARC-Block-Digest", Digest(rec.Content))
+	if rec.Type == TypeResponse || rec.Type == TypeRequest {
+		field("WARC-Payload-Digest", Digest(Payload(rec.Content)))
+	}
+	field("Content-Length", strconv.Itoa(len(rec.Content)))
+	b.WriteString("\r\n")
+	b.Write(rec.Content)
+	b.WriteString("\r\n\r\n")
+	return b.Bytes()
+}
+
+// parseHeader reads a record's version line and named fields up to the
+// blank line that precedes its block
+func parseHeader(r *Reader) (*Record, int64, error) {
+	var line string
+	var err error
+	// Tolerate stray blank lines between records
+	for line == "" {
+		if line, err = r.line(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if !strings.HasPrefix(line, "WARC/1.") {
+		return nil, 0, fmt.Errorf("invalid WARC record: unexpected %q", line)
+	}
+
+	rec := &Record{Fields: make(map[string]string)}
+	length := int64(-1)
+	for {
+		line, err = r.line()
+		if err != nil {
+			return nil, 0, unexpected(err)
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid WARC header line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(name) {
+		case "warc-type":
+			rec.Type = value
+		case "warc-record-id":
+			rec.ID = value
+		case "warc-date":
+			if rec.Date, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return nil, 0, fmt.Errorf("invalid WARC-Date %q: %w", value, err)
+			}
+		case "warc-target-uri":
+			rec.TargetURI = strings.Trim(value, "<>")
+		case "warc-concurrent-to":
+			rec.ConcurrentTo = value
+		case "content-type":
+			rec.ContentType = value
+		case "content-length":
+			if length, err = strconv.ParseInt(value, 10, 64); err != nil || length < 0 {
+				return nil, 0, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		default:
+			rec.Fields[name] = value
+		}
+	}
+	if length < 0 {
+		return nil, 0, fmt.Errorf("WARC record %s has no Content-Length", rec.ID)
+	}
+	return rec, length, nil
+}
+
+// unexpected turns io.EOF inside a record into io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
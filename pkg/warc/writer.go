@@ -0,0 +1,138 @@
+package warc
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Writer writes gzip-compressed WARC files, one gzip member per record, and
+// starts a new file once the current one reaches its size limit. It is safe
+// for concurrent use.
+type Writer struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	maxSize  int64
+	software string
+	file     *os.File
+	size     int64
+	serial   int
+	files    []string
+}
+
+// NewWriter creates a writer for files named <prefix>-<timestamp>-<serial>.warc.gz
+// in dir. A maxSize of zero or less never rotates. software is recorded in
+// the warcinfo record that opens every file.
+func NewWriter(dir, prefix string, maxSize int64, software string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create WARC directory: %w", err)
+	}
+	return &Writer{dir: dir, prefix: prefix, maxSize: maxSize, software: software}, nil
+}
+
+// Write appends records to the current file. Records written together always
+// land in the same file, so a request stays next to its response.
+func (w *Writer) Write(records ...*Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || (w.maxSize > 0 && w.size >= w.maxSize) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	for _, rec := range records {
+		if err := w.write(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Files lists the files written so far, oldest first
+func (w *Writer) Files() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.files...)
+}
+
+// Close closes the current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate closes the current file and opens the next one with its warcinfo
+// record. The caller must hold w.mu.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close WARC file: %w", err)
+		}
+		w.file = nil
+	}
+
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, time.Now().UTC().Format("20060102150405"), w.serial)
+	path := filepath.Join(w.dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create WARC file: %w", err)
+	}
+	w.file = f
+	w.size = 0
+	w.serial++
+	w.files = append(w.files, path)
+
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n"+
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n", w.software)
+	return w.write(&Record{
+		Type:        TypeWarcinfo,
+		ContentType: "application/warc-fields",
+		Fields:      map[string]string{"WARC-Filename": name},
+		Content:     []byte(info),
+	})
+}
+
+// write compresses rec as its own gzip member. The caller must hold w.mu.
+func (w *Writer) write(rec *Record) error {
+	if rec.ID == "" {
+		rec.ID = NewID()
+	}
+	if rec.Date.IsZero() {
+		rec.Date = time.Now()
+	}
+
+	counter := &countingWriter{w: w.file}
+	gz := gzip.NewWriter(counter)
+	if _, err := gz.Write(rec.marshal()); err != nil {
+		return fmt.Errorf("failed to write WARC record: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write WARC record: %w", err)
+	}
+	w.size += counter.n
+	return nil
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}